@@ -0,0 +1,102 @@
+package diagnostic
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/Yarik7610/expressive/lexer"
+	"github.com/Yarik7610/expressive/parser"
+)
+
+// Render formats err as a compiler-style diagnostic: the message, the source
+// line the error points at and a "^~~~" underline below the offending span.
+// Errors that don't carry a position are rendered as a single line, errors
+// that wrap several errors (like parser.ErrorList) are rendered one by one.
+func Render(source string, err error) string {
+	if list, ok := err.(interface{ Unwrap() []error }); ok {
+		var b strings.Builder
+		for _, err := range list.Unwrap() {
+			b.WriteString(Render(source, err))
+		}
+		return b.String()
+	}
+
+	message, span, ok := locate(err)
+	if !ok || !span.Start.IsValid() {
+		return fmt.Sprintf("error: %s\n", err)
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "error: %s\n", message)
+
+	lines := strings.Split(source, "\n")
+	if span.Start.Line > len(lines) {
+		fmt.Fprintf(&b, " --> %s\n", span.Start)
+		return b.String()
+	}
+	line := strings.TrimRight(lines[span.Start.Line-1], "\r")
+
+	gutter := fmt.Sprint(span.Start.Line)
+	padding := strings.Repeat(" ", len(gutter))
+
+	fmt.Fprintf(&b, "%s--> %s\n", padding, span.Start)
+	fmt.Fprintf(&b, "%s |\n", padding)
+	fmt.Fprintf(&b, "%s | %s\n", gutter, line)
+	fmt.Fprintf(&b, "%s | %s\n", padding, underline(line, span))
+
+	return b.String()
+}
+
+func locate(err error) (string, lexer.Span, bool) {
+	var lexErr *lexer.LexError
+	if errors.As(err, &lexErr) {
+		return lexErr.Message, lexErr.Span, true
+	}
+
+	var syntaxErr *parser.SyntaxError
+	if errors.As(err, &syntaxErr) {
+		return syntaxErr.Message, syntaxErr.Span, true
+	}
+
+	var evalErr *parser.EvalError
+	if errors.As(err, &evalErr) {
+		return evalErr.Message, evalErr.Span, true
+	}
+
+	return "", lexer.Span{}, false
+}
+
+func underline(line string, span lexer.Span) string {
+	var b strings.Builder
+
+	//keep tabs, so the caret lines up with the source no matter the tab width
+	column := 1
+	for _, r := range line {
+		if column == span.Start.Column {
+			break
+		}
+		if r == '\t' {
+			b.WriteRune('\t')
+		} else {
+			b.WriteRune(' ')
+		}
+		column++
+	}
+	for ; column < span.Start.Column; column++ {
+		b.WriteRune(' ')
+	}
+
+	var width int
+	if span.End.Line == span.Start.Line {
+		width = max(span.End.Column-span.Start.Column, 1)
+	} else {
+		width = max(utf8.RuneCountInString(line)-span.Start.Column+1, 1)
+	}
+
+	b.WriteRune('^')
+	b.WriteString(strings.Repeat("~", width-1))
+
+	return b.String()
+}
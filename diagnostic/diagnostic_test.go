@@ -0,0 +1,83 @@
+package diagnostic
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/Yarik7610/expressive/lexer"
+	"github.com/Yarik7610/expressive/parser"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRender(t *testing.T) {
+	tests := []struct {
+		Name string
+		In   string
+		Out  string
+	}{
+		{
+			Name: "unclosed bracket",
+			In:   "2 * (3 + 4",
+			Out: "error: unclosed '('\n" +
+				" --> 1:5\n" +
+				"  |\n" +
+				"1 | 2 * (3 + 4\n" +
+				"  |     ^\n",
+		},
+		{
+			Name: "malformed number on second line",
+			In:   "1 +\n\t2 * 3e2.5",
+			Out: "error: exponent notation has wrong format: detected '.' in power\n" +
+				" --> 2:6\n" +
+				"  |\n" +
+				"2 | \t2 * 3e2.5\n" +
+				"  | \t    ^~~~\n",
+		},
+		{
+			Name: "several broken expressions",
+			In:   "1 2\n3 +",
+			Out: "error: expected end of statement, found '2'\n" +
+				" --> 1:3\n" +
+				"  |\n" +
+				"1 | 1 2\n" +
+				"  |   ^\n" +
+				"error: expected number, variable or '(', found end of input\n" +
+				" --> 2:4\n" +
+				"  |\n" +
+				"2 | 3 +\n" +
+				"  |    ^\n",
+		},
+		{
+			Name: "unknown token",
+			In:   "1 $ 2",
+			Out: "error: detected unknown token: '$'\n" +
+				" --> 1:3\n" +
+				"  |\n" +
+				"1 | 1 $ 2\n" +
+				"  |   ^\n",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			assert.Equal(t, test.Out, Render(test.In, process(test.In)))
+		})
+	}
+}
+
+func TestRenderWithoutPosition(t *testing.T) {
+	_, err := parser.Eval([]parser.Node{}, nil)
+	assert.Equal(t, "error: eval: no nodes provided\n", Render("", err))
+
+	assert.Equal(t, "error: boom\n", Render("1+2", errors.New("boom")))
+}
+
+func process(input string) error {
+	tokens, err := lexer.NewLexer(strings.NewReader(input)).Lex()
+	if err != nil {
+		return err
+	}
+	_, err = parser.NewParser(tokens).Parse()
+	return err
+}
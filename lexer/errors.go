@@ -0,0 +1,16 @@
+package lexer
+
+import "fmt"
+
+// LexError is returned by Lex when the input can't be broken into tokens.
+type LexError struct {
+	Message string
+	Span    Span
+}
+
+func (e *LexError) Error() string {
+	if !e.Span.Start.IsValid() {
+		return fmt.Sprintf("lexer: %s", e.Message)
+	}
+	return fmt.Sprintf("lexer: %s: %s", e.Span.Start, e.Message)
+}
@@ -5,26 +5,31 @@ import (
 	"bytes"
 	"fmt"
 	"io"
+	"unicode"
 	"unicode/utf8"
 )
 
 type Lexer struct {
 	scanner bufio.Reader
 	cur     rune
+	//pos is the position of l.cur, next is the position of the rune after it
+	pos  Position
+	next Position
 }
 
 func NewLexer(reader io.Reader) *Lexer {
-	l := &Lexer{scanner: *bufio.NewReader(reader)}
+	l := &Lexer{scanner: *bufio.NewReader(reader), next: Position{Offset: 0, Line: 1, Column: 1}}
 	//advance on init so l.cur wasn't 0 (EOF)
 	l.advance()
 	return l
 }
 
-func (l *Lexer) Lex() []Token {
+func (l *Lexer) Lex() ([]Token, error) {
 	tokens := make([]Token, 0)
 
 	for l.cur != 0 {
 		tokenType := TOKEN_UNKNOWN
+		start := l.pos
 
 		switch l.cur {
 		case '#':
@@ -47,42 +52,127 @@ func (l *Lexer) Lex() []Token {
 			tokenType = TOKEN_PERCENT
 		case '^':
 			tokenType = TOKEN_CARET
+		case '=':
+			tokens = append(tokens, l.either('=', TOKEN_EQUAL, TOKEN_ASSIGN))
+			continue
+		case '<':
+			if l.peek() == '<' {
+				tokens = append(tokens, l.either('<', TOKEN_SHIFT_LEFT, TOKEN_LESS))
+				continue
+			}
+			tokens = append(tokens, l.either('=', TOKEN_LESS_EQUAL, TOKEN_LESS))
+			continue
+		case '>':
+			if l.peek() == '>' {
+				tokens = append(tokens, l.either('>', TOKEN_SHIFT_RIGHT, TOKEN_GREATER))
+				continue
+			}
+			tokens = append(tokens, l.either('=', TOKEN_GREATER_EQUAL, TOKEN_GREATER))
+			continue
+		case '!':
+			tokens = append(tokens, l.either('=', TOKEN_NOT_EQUAL, TOKEN_NOT))
+			continue
+		case '&':
+			tokens = append(tokens, l.either('&', TOKEN_AND, TOKEN_AMPERSAND))
+			continue
+		case '|':
+			tokens = append(tokens, l.either('|', TOKEN_OR, TOKEN_PIPE))
+			continue
+		case '~':
+			tokenType = TOKEN_TILDE
 		case '(':
 			tokenType = TOKEN_BRACE_LEFT
 		case ')':
 			tokenType = TOKEN_BRACE_RIGHT
+		case ';':
+			tokenType = TOKEN_SEMICOLON
+		case ',':
+			tokenType = TOKEN_COMMA
+		case '?':
+			tokenType = TOKEN_QUESTION
+		case ':':
+			tokenType = TOKEN_COLON
 		default:
 			if (l.cur >= '0' && l.cur <= '9') || l.cur == '.' {
-				tokens = append(tokens, l.number())
+				token, err := l.number()
+				if err != nil {
+					return nil, err
+				}
+				tokens = append(tokens, token)
+				continue
+			}
+			if unicode.IsLetter(l.cur) {
+				tokens = append(tokens, l.identifier())
 				continue
 			}
 		}
 
 		if tokenType == TOKEN_UNKNOWN {
-			panic(fmt.Sprintf("lexer: detected unknown token: %q", l.cur))
+			return nil, &LexError{fmt.Sprintf("detected unknown token: %q", l.cur), Span{start, l.next}}
 		}
 
-		tokens = append(tokens, Token{tokenType, string(l.cur)})
+		raw := string(l.cur)
 		l.advance()
+		tokens = append(tokens, Token{tokenType, raw, Span{start, l.pos}})
 	}
 
-	tokens = append(tokens, Token{TOKEN_EOF, "TOKEN_EOF"})
+	tokens = append(tokens, Token{TOKEN_EOF, "TOKEN_EOF", Span{l.pos, l.pos}})
 
-	return tokens
+	return tokens, nil
 }
 
 func (l *Lexer) advance() {
-	r, _, err := l.scanner.ReadRune()
+	l.pos = l.next
+
+	r, size, err := l.scanner.ReadRune()
 	if err != nil {
 		//If err, we end advancing like if it was an EOF
 		l.cur = 0
 		return
 	}
 	l.cur = r
+
+	l.next.Offset += size
+	if r == '\n' {
+		l.next.Line++
+		l.next.Column = 1
+	} else {
+		l.next.Column++
+	}
 }
 
-func (l *Lexer) number() Token {
+// peek returns the rune after l.cur without advancing, or 0 at the end of
+// input.
+func (l *Lexer) peek() rune {
+	buf, _ := l.scanner.Peek(utf8.UTFMax)
+	if len(buf) == 0 {
+		return 0
+	}
+	r, _ := utf8.DecodeRune(buf)
+	return r
+}
+
+// either lexes an operator of two runes when the rune after the current one is
+// second, or an operator of just the current rune otherwise.
+func (l *Lexer) either(second rune, double int, single int) Token {
+	start := l.pos
+	first := l.cur
+	l.advance()
+
+	if l.cur == second {
+		l.advance()
+		return Token{double, string([]rune{first, second}), Span{start, l.pos}}
+	}
+	return Token{single, string(first), Span{start, l.pos}}
+}
+
+func (l *Lexer) number() (Token, error) {
+	if _, ok := numberBases[unicode.ToLower(l.peek())]; ok && l.cur == '0' {
+		return l.baseNumber()
+	}
+
 	var b bytes.Buffer
+	start := l.pos
 
 	dotCount := 0
 	exponentNotation := false
@@ -90,15 +180,15 @@ func (l *Lexer) number() Token {
 
 	for (l.cur >= '0' && l.cur <= '9') || l.cur == 'e' || l.cur == '_' || l.cur == '.' || l.cur == '+' || l.cur == '-' {
 		if prevCh == l.cur && (l.cur == 'e' || l.cur == '_' || l.cur == '.') {
-			panic(fmt.Sprintf("lexer: detected adjacent %q", l.cur))
+			return Token{}, &LexError{fmt.Sprintf("detected adjacent %q", l.cur), Span{start, l.next}}
 		}
 
 		if exponentNotation {
 			if prevCh == 'e' && (l.cur == '.' || l.cur == '_') {
-				panic(fmt.Sprintf("lexer: exponent notation has wrong format: %q detected after 'e'", l.cur))
+				return Token{}, &LexError{fmt.Sprintf("exponent notation has wrong format: %q detected after 'e'", l.cur), Span{start, l.next}}
 			}
 			if prevCh != 'e' && (l.cur == '.' || l.cur == '+' || l.cur == '-') {
-				panic(fmt.Sprintf("lexer: exponent notation has wrong format: detected %q in power", l.cur))
+				return Token{}, &LexError{fmt.Sprintf("exponent notation has wrong format: detected %q in power", l.cur), Span{start, l.next}}
 			}
 		} else {
 			if l.cur == '+' || l.cur == '-' {
@@ -112,7 +202,7 @@ func (l *Lexer) number() Token {
 			dotCount++
 		}
 		if dotCount > 1 {
-			panic(fmt.Sprintf("lexer: %q was detected in number more than once", l.cur))
+			return Token{}, &LexError{fmt.Sprintf("%q was detected in number more than once", l.cur), Span{start, l.next}}
 		}
 
 		b.WriteRune(l.cur)
@@ -123,10 +213,119 @@ func (l *Lexer) number() Token {
 	raw := b.Bytes()
 	r, _ := utf8.DecodeLastRune(raw)
 	if r == '_' || r == 'e' {
-		panic(fmt.Sprintf("lexer: %q must separate successive digits", r))
+		return Token{}, &LexError{fmt.Sprintf("%q must separate successive digits", r), Span{start, l.pos}}
 	}
 
-	return Token{TOKEN_NUMBER, b.String()}
+	return l.numberToken(&b, start), nil
+}
+
+type numberBase struct {
+	base int
+	name string
+}
+
+// numberBases are the bases of integer literals by the letter after their
+// leading 0, like 0xFF.
+var numberBases = map[rune]numberBase{
+	'x': {16, "hexadecimal"},
+	'b': {2, "binary"},
+	'o': {8, "octal"},
+}
+
+// baseNumber lexes a hexadecimal, binary or octal integer like 0xFF,
+// 0b1010_0101 or 0o755. Underscores follow the rules of decimal numbers.
+func (l *Lexer) baseNumber() (Token, error) {
+	var b bytes.Buffer
+	start := l.pos
+
+	b.WriteRune(l.cur)
+	l.advance()
+	nb := numberBases[unicode.ToLower(l.cur)]
+	b.WriteRune(l.cur)
+	l.advance()
+
+	digits := 0
+	var prevCh rune
+	for isIdentifierRune(l.cur) {
+		//the suffix of an imaginary number, 'i' is never a digit
+		if l.cur == 'i' && !isIdentifierRune(l.peek()) {
+			break
+		}
+
+		if l.cur == '_' {
+			if prevCh == '_' {
+				return Token{}, &LexError{fmt.Sprintf("detected adjacent %q", l.cur), Span{start, l.next}}
+			}
+			if digits == 0 {
+				return Token{}, &LexError{fmt.Sprintf("%q must separate successive digits", l.cur), Span{start, l.next}}
+			}
+		} else if digitValue(l.cur) >= nb.base {
+			return Token{}, &LexError{fmt.Sprintf("invalid digit %q in %s number", l.cur, nb.name), Span{start, l.next}}
+		} else {
+			digits++
+		}
+
+		b.WriteRune(l.cur)
+		prevCh = l.cur
+		l.advance()
+	}
+
+	if digits == 0 {
+		return Token{}, &LexError{fmt.Sprintf("%s number '%s' has no digits", nb.name, b.String()), Span{start, l.pos}}
+	}
+	if prevCh == '_' {
+		return Token{}, &LexError{fmt.Sprintf("%q must separate successive digits", prevCh), Span{start, l.pos}}
+	}
+	if l.cur == '.' {
+		return Token{}, &LexError{fmt.Sprintf("%s number can't have a fractional part", nb.name), Span{start, l.next}}
+	}
+
+	return l.numberToken(&b, start), nil
+}
+
+// numberToken finishes a number lexed into b, reading the suffix of an
+// imaginary number like 2i, but not the start of a word like "in".
+func (l *Lexer) numberToken(b *bytes.Buffer, start Position) Token {
+	tokenType := TOKEN_NUMBER
+	if l.cur == 'i' && !isIdentifierRune(l.peek()) {
+		b.WriteRune(l.cur)
+		l.advance()
+		tokenType = TOKEN_IMAGINARY
+	}
+	return Token{tokenType, b.String(), Span{start, l.pos}}
+}
+
+// digitValue returns the value of r as a digit of bases up to 36, or 36 for
+// runes that aren't digits of any of them.
+func digitValue(r rune) int {
+	switch {
+	case r >= '0' && r <= '9':
+		return int(r - '0')
+	case r >= 'a' && r <= 'z':
+		return int(r-'a') + 10
+	case r >= 'A' && r <= 'Z':
+		return int(r-'A') + 10
+	}
+	return 36
+}
+
+func (l *Lexer) identifier() Token {
+	var b bytes.Buffer
+	start := l.pos
+
+	for isIdentifierRune(l.cur) {
+		b.WriteRune(l.cur)
+		l.advance()
+	}
+
+	if tokenType, ok := KEYWORDS[b.String()]; ok {
+		return Token{tokenType, b.String(), Span{start, l.pos}}
+	}
+	return Token{TOKEN_IDENT, b.String(), Span{start, l.pos}}
+}
+
+func isIdentifierRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_'
 }
 
 func PrintTokens(tokens []Token) {
@@ -8,7 +8,7 @@ import (
 )
 
 func TestLexer(t *testing.T) {
-	nonPanicTests := []struct {
+	nonErrorTests := []struct {
 		Name string
 		In   string
 		Out  []Token
@@ -16,78 +16,241 @@ func TestLexer(t *testing.T) {
 		{
 			Name: "empty input",
 			In:   "",
-			Out:  []Token{{TOKEN_EOF, "TOKEN_EOF"}},
+			Out:  []Token{{Type: TOKEN_EOF, Raw: "TOKEN_EOF"}},
 		},
 		{
 			Name: "ignoring whitespaces",
 			In:   "\r\t\n     ",
-			Out:  []Token{{TOKEN_EOF, "TOKEN_EOF"}},
+			Out:  []Token{{Type: TOKEN_EOF, Raw: "TOKEN_EOF"}},
 		},
 		{
 			Name: "ignoring comments",
 			In:   "# this is a comment\n# this is a comment without a newline at the end",
-			Out:  []Token{{TOKEN_EOF, "TOKEN_EOF"}},
+			Out:  []Token{{Type: TOKEN_EOF, Raw: "TOKEN_EOF"}},
 		},
 		{
 			Name: "operator and separator tokens lex",
-			In:   "+-/*^%()",
+			In:   "+-/*^%=();,?:",
 			Out: []Token{
-				{TOKEN_PLUS, "+"},
-				{TOKEN_MINUS, "-"},
-				{TOKEN_SLASH, "/"},
-				{TOKEN_ASTERISK, "*"},
-				{TOKEN_CARET, "^"},
-				{TOKEN_PERCENT, "%"},
-				{TOKEN_BRACE_LEFT, "("},
-				{TOKEN_BRACE_RIGHT, ")"},
-				{TOKEN_EOF, "TOKEN_EOF"},
+				{Type: TOKEN_PLUS, Raw: "+"},
+				{Type: TOKEN_MINUS, Raw: "-"},
+				{Type: TOKEN_SLASH, Raw: "/"},
+				{Type: TOKEN_ASTERISK, Raw: "*"},
+				{Type: TOKEN_CARET, Raw: "^"},
+				{Type: TOKEN_PERCENT, Raw: "%"},
+				{Type: TOKEN_ASSIGN, Raw: "="},
+				{Type: TOKEN_BRACE_LEFT, Raw: "("},
+				{Type: TOKEN_BRACE_RIGHT, Raw: ")"},
+				{Type: TOKEN_SEMICOLON, Raw: ";"},
+				{Type: TOKEN_COMMA, Raw: ","},
+				{Type: TOKEN_QUESTION, Raw: "?"},
+				{Type: TOKEN_COLON, Raw: ":"},
+				{Type: TOKEN_EOF, Raw: "TOKEN_EOF"},
 			},
 		},
 		{
 			Name: "number",
 			In:   "123",
-			Out:  []Token{{TOKEN_NUMBER, "123"}, {TOKEN_EOF, "TOKEN_EOF"}},
+			Out:  []Token{{Type: TOKEN_NUMBER, Raw: "123"}, {Type: TOKEN_EOF, Raw: "TOKEN_EOF"}},
 		},
 		{
 			Name: "underscore number",
 			In:   "123_000",
-			Out:  []Token{{TOKEN_NUMBER, "123_000"}, {TOKEN_EOF, "TOKEN_EOF"}},
+			Out:  []Token{{Type: TOKEN_NUMBER, Raw: "123_000"}, {Type: TOKEN_EOF, Raw: "TOKEN_EOF"}},
 		},
 		{
 			Name: "number with e and underscore in power",
 			In:   "123e2_0",
-			Out:  []Token{{TOKEN_NUMBER, "123e2_0"}, {TOKEN_EOF, "TOKEN_EOF"}},
+			Out:  []Token{{Type: TOKEN_NUMBER, Raw: "123e2_0"}, {Type: TOKEN_EOF, Raw: "TOKEN_EOF"}},
 		},
 		{
 			Name: "number with e and next '+'",
 			In:   "123e+2",
-			Out:  []Token{{TOKEN_NUMBER, "123e+2"}, {TOKEN_EOF, "TOKEN_EOF"}},
+			Out:  []Token{{Type: TOKEN_NUMBER, Raw: "123e+2"}, {Type: TOKEN_EOF, Raw: "TOKEN_EOF"}},
 		},
 		{
 			Name: "number with e and next '-'",
 			In:   "123e-2",
-			Out:  []Token{{TOKEN_NUMBER, "123e-2"}, {TOKEN_EOF, "TOKEN_EOF"}},
+			Out:  []Token{{Type: TOKEN_NUMBER, Raw: "123e-2"}, {Type: TOKEN_EOF, Raw: "TOKEN_EOF"}},
 		},
 		{
 			Name: "number with . at the start",
 			In:   ".5",
-			Out:  []Token{{TOKEN_NUMBER, ".5"}, {TOKEN_EOF, "TOKEN_EOF"}},
+			Out:  []Token{{Type: TOKEN_NUMBER, Raw: ".5"}, {Type: TOKEN_EOF, Raw: "TOKEN_EOF"}},
 		},
 		{
 			Name: "number with . at the end",
 			In:   "5.",
-			Out:  []Token{{TOKEN_NUMBER, "5."}, {TOKEN_EOF, "TOKEN_EOF"}},
+			Out:  []Token{{Type: TOKEN_NUMBER, Raw: "5."}, {Type: TOKEN_EOF, Raw: "TOKEN_EOF"}},
+		},
+		{
+			Name: "imaginary number",
+			In:   "2.5i+1e2i",
+			Out: []Token{
+				{Type: TOKEN_IMAGINARY, Raw: "2.5i"},
+				{Type: TOKEN_PLUS, Raw: "+"},
+				{Type: TOKEN_IMAGINARY, Raw: "1e2i"},
+				{Type: TOKEN_EOF, Raw: "TOKEN_EOF"},
+			},
+		},
+		{
+			Name: "number followed by an identifier starting with i",
+			In:   "2int 3ix",
+			Out: []Token{
+				{Type: TOKEN_NUMBER, Raw: "2"},
+				{Type: TOKEN_IDENT, Raw: "int"},
+				{Type: TOKEN_NUMBER, Raw: "3"},
+				{Type: TOKEN_IDENT, Raw: "ix"},
+				{Type: TOKEN_EOF, Raw: "TOKEN_EOF"},
+			},
+		},
+		{
+			Name: "hexadecimal, binary and octal numbers",
+			In:   "0xFF+0b1010_0101*0o755-0Xdead_beef",
+			Out: []Token{
+				{Type: TOKEN_NUMBER, Raw: "0xFF"},
+				{Type: TOKEN_PLUS, Raw: "+"},
+				{Type: TOKEN_NUMBER, Raw: "0b1010_0101"},
+				{Type: TOKEN_ASTERISK, Raw: "*"},
+				{Type: TOKEN_NUMBER, Raw: "0o755"},
+				{Type: TOKEN_MINUS, Raw: "-"},
+				{Type: TOKEN_NUMBER, Raw: "0Xdead_beef"},
+				{Type: TOKEN_EOF, Raw: "TOKEN_EOF"},
+			},
+		},
+		{
+			Name: "hexadecimal imaginary number",
+			In:   "0x1Fi",
+			Out:  []Token{{Type: TOKEN_IMAGINARY, Raw: "0x1Fi"}, {Type: TOKEN_EOF, Raw: "TOKEN_EOF"}},
+		},
+		{
+			Name: "identifiers",
+			In:   "df+123 x_1 e123",
+			Out: []Token{
+				{Type: TOKEN_IDENT, Raw: "df"},
+				{Type: TOKEN_PLUS, Raw: "+"},
+				{Type: TOKEN_NUMBER, Raw: "123"},
+				{Type: TOKEN_IDENT, Raw: "x_1"},
+				{Type: TOKEN_IDENT, Raw: "e123"},
+				{Type: TOKEN_EOF, Raw: "TOKEN_EOF"},
+			},
+		},
+		{
+			Name: "e as an identifier next to exponent notation",
+			In:   "2e1*e-e^2",
+			Out: []Token{
+				{Type: TOKEN_NUMBER, Raw: "2e1"},
+				{Type: TOKEN_ASTERISK, Raw: "*"},
+				{Type: TOKEN_IDENT, Raw: "e"},
+				{Type: TOKEN_MINUS, Raw: "-"},
+				{Type: TOKEN_IDENT, Raw: "e"},
+				{Type: TOKEN_CARET, Raw: "^"},
+				{Type: TOKEN_NUMBER, Raw: "2"},
+				{Type: TOKEN_EOF, Raw: "TOKEN_EOF"},
+			},
+		},
+		{
+			Name: "comparison operators",
+			In:   "< <= > >= == != =<",
+			Out: []Token{
+				{Type: TOKEN_LESS, Raw: "<"},
+				{Type: TOKEN_LESS_EQUAL, Raw: "<="},
+				{Type: TOKEN_GREATER, Raw: ">"},
+				{Type: TOKEN_GREATER_EQUAL, Raw: ">="},
+				{Type: TOKEN_EQUAL, Raw: "=="},
+				{Type: TOKEN_NOT_EQUAL, Raw: "!="},
+				{Type: TOKEN_ASSIGN, Raw: "="},
+				{Type: TOKEN_LESS, Raw: "<"},
+				{Type: TOKEN_EOF, Raw: "TOKEN_EOF"},
+			},
+		},
+		{
+			Name: "logical operators",
+			In:   "&& || ! and or not android",
+			Out: []Token{
+				{Type: TOKEN_AND, Raw: "&&"},
+				{Type: TOKEN_OR, Raw: "||"},
+				{Type: TOKEN_NOT, Raw: "!"},
+				{Type: TOKEN_AND, Raw: "and"},
+				{Type: TOKEN_OR, Raw: "or"},
+				{Type: TOKEN_NOT, Raw: "not"},
+				{Type: TOKEN_IDENT, Raw: "android"},
+				{Type: TOKEN_EOF, Raw: "TOKEN_EOF"},
+			},
+		},
+		{
+			Name: "bitwise operators",
+			In:   "1 & 2 | ~3 xor 4 << 5 >> 6 <<= >>=",
+			Out: []Token{
+				{Type: TOKEN_NUMBER, Raw: "1"},
+				{Type: TOKEN_AMPERSAND, Raw: "&"},
+				{Type: TOKEN_NUMBER, Raw: "2"},
+				{Type: TOKEN_PIPE, Raw: "|"},
+				{Type: TOKEN_TILDE, Raw: "~"},
+				{Type: TOKEN_NUMBER, Raw: "3"},
+				{Type: TOKEN_XOR, Raw: "xor"},
+				{Type: TOKEN_NUMBER, Raw: "4"},
+				{Type: TOKEN_SHIFT_LEFT, Raw: "<<"},
+				{Type: TOKEN_NUMBER, Raw: "5"},
+				{Type: TOKEN_SHIFT_RIGHT, Raw: ">>"},
+				{Type: TOKEN_NUMBER, Raw: "6"},
+				{Type: TOKEN_SHIFT_LEFT, Raw: "<<"},
+				{Type: TOKEN_ASSIGN, Raw: "="},
+				{Type: TOKEN_SHIFT_RIGHT, Raw: ">>"},
+				{Type: TOKEN_ASSIGN, Raw: "="},
+				{Type: TOKEN_EOF, Raw: "TOKEN_EOF"},
+			},
+		},
+		{
+			Name: "format directives",
+			In:   "255in hex as inch",
+			Out: []Token{
+				{Type: TOKEN_NUMBER, Raw: "255"},
+				{Type: TOKEN_IN, Raw: "in"},
+				{Type: TOKEN_IDENT, Raw: "hex"},
+				{Type: TOKEN_AS, Raw: "as"},
+				{Type: TOKEN_IDENT, Raw: "inch"},
+				{Type: TOKEN_EOF, Raw: "TOKEN_EOF"},
+			},
+		},
+		{
+			Name: "units",
+			In:   "60 mph to m/s^2 tom",
+			Out: []Token{
+				{Type: TOKEN_NUMBER, Raw: "60"},
+				{Type: TOKEN_IDENT, Raw: "mph"},
+				{Type: TOKEN_TO, Raw: "to"},
+				{Type: TOKEN_IDENT, Raw: "m"},
+				{Type: TOKEN_SLASH, Raw: "/"},
+				{Type: TOKEN_IDENT, Raw: "s"},
+				{Type: TOKEN_CARET, Raw: "^"},
+				{Type: TOKEN_NUMBER, Raw: "2"},
+				{Type: TOKEN_IDENT, Raw: "tom"},
+				{Type: TOKEN_EOF, Raw: "TOKEN_EOF"},
+			},
+		},
+		{
+			Name: "conditional keyword",
+			In:   "if(c, 1, 2) iffy",
+			Out: []Token{
+				{Type: TOKEN_IF, Raw: "if"},
+				{Type: TOKEN_BRACE_LEFT, Raw: "("},
+				{Type: TOKEN_IDENT, Raw: "c"},
+				{Type: TOKEN_COMMA, Raw: ","},
+				{Type: TOKEN_NUMBER, Raw: "1"},
+				{Type: TOKEN_COMMA, Raw: ","},
+				{Type: TOKEN_NUMBER, Raw: "2"},
+				{Type: TOKEN_BRACE_RIGHT, Raw: ")"},
+				{Type: TOKEN_IDENT, Raw: "iffy"},
+				{Type: TOKEN_EOF, Raw: "TOKEN_EOF"},
+			},
 		},
 	}
 
-	panicTests := []struct {
+	errorTests := []struct {
 		Name string
 		In   string
 	}{
-		{
-			Name: "expression with alphabet chars",
-			In:   "df+123",
-		},
 		{
 			Name: "number with underscore at the start",
 			In:   "_123",
@@ -96,10 +259,6 @@ func TestLexer(t *testing.T) {
 			Name: "number with underscore at the end",
 			In:   "123_",
 		},
-		{
-			Name: "number with e at the start",
-			In:   "e123",
-		},
 		{
 			Name: "number with e at the end",
 			In:   "123e",
@@ -140,6 +299,38 @@ func TestLexer(t *testing.T) {
 			Name: "number with many adjacent '_'",
 			In:   "5e2__0",
 		},
+		{
+			Name: "hexadecimal number without digits",
+			In:   "0x",
+		},
+		{
+			Name: "hexadecimal number with invalid digit",
+			In:   "0xFG",
+		},
+		{
+			Name: "binary number with invalid digit",
+			In:   "0b102",
+		},
+		{
+			Name: "octal number with invalid digit",
+			In:   "0o758",
+		},
+		{
+			Name: "hexadecimal number with '_' after the prefix",
+			In:   "0x_FF",
+		},
+		{
+			Name: "binary number with '_' at the end",
+			In:   "0b1_",
+		},
+		{
+			Name: "binary number with many adjacent '_'",
+			In:   "0b1__0",
+		},
+		{
+			Name: "hexadecimal number with fractional part",
+			In:   "0x1.8",
+		},
 		{
 			Name: "number with many adjacent 'e'",
 			In:   "5ee20",
@@ -150,20 +341,54 @@ func TestLexer(t *testing.T) {
 		},
 	}
 
-	for _, test := range nonPanicTests {
+	for _, test := range nonErrorTests {
 		t.Run(test.Name, func(t *testing.T) {
 			r := strings.NewReader(test.In)
-			out := NewLexer(r).Lex()
-			assert.EqualValues(t, test.Out, out)
+			out, err := NewLexer(r).Lex()
+			assert.NoError(t, err)
+			assert.EqualValues(t, test.Out, withoutSpans(out))
 		})
 	}
 
-	for _, test := range panicTests {
+	for _, test := range errorTests {
 		t.Run(test.Name, func(t *testing.T) {
 			r := strings.NewReader(test.In)
-			assert.Panics(t, func() {
-				NewLexer(r).Lex()
-			})
+			_, err := NewLexer(r).Lex()
+			var lexErr *LexError
+			assert.ErrorAs(t, err, &lexErr)
 		})
 	}
 }
+
+func TestLexerPositions(t *testing.T) {
+	r := strings.NewReader("12 +\n  (3.5e1)")
+	out, err := NewLexer(r).Lex()
+	assert.NoError(t, err)
+
+	expected := []Span{
+		{Position{0, 1, 1}, Position{2, 1, 3}},
+		{Position{3, 1, 4}, Position{4, 1, 5}},
+		{Position{7, 2, 3}, Position{8, 2, 4}},
+		{Position{8, 2, 4}, Position{13, 2, 9}},
+		{Position{13, 2, 9}, Position{14, 2, 10}},
+		{Position{14, 2, 10}, Position{14, 2, 10}},
+	}
+	spans := make([]Span, 0, len(out))
+	for _, token := range out {
+		spans = append(spans, token.Span)
+	}
+	assert.Equal(t, expected, spans)
+
+	_, err = NewLexer(strings.NewReader("1 +\n 2 $")).Lex()
+	var lexErr *LexError
+	if assert.ErrorAs(t, err, &lexErr) {
+		assert.Equal(t, Span{Position{7, 2, 4}, Position{8, 2, 5}}, lexErr.Span)
+	}
+}
+
+func withoutSpans(tokens []Token) []Token {
+	for i := range tokens {
+		tokens[i].Span = Span{}
+	}
+	return tokens
+}
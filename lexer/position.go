@@ -0,0 +1,31 @@
+package lexer
+
+import "fmt"
+
+// Position points at a rune of the input. Offset is in bytes and starts at 0,
+// Line and Column are counted in runes and start at 1.
+type Position struct {
+	Offset int
+	Line   int
+	Column int
+}
+
+// IsValid reports whether the position points into the input. Tokens built by
+// hand, without a lexer, have zero positions.
+func (p Position) IsValid() bool {
+	return p.Line > 0
+}
+
+func (p Position) String() string {
+	return fmt.Sprintf("%d:%d", p.Line, p.Column)
+}
+
+// Span covers input from Start up to, but not including, End.
+type Span struct {
+	Start Position
+	End   Position
+}
+
+func (s Span) String() string {
+	return fmt.Sprintf("%s-%s", s.Start, s.End)
+}
@@ -3,6 +3,8 @@ package lexer
 const (
 	TOKEN_UNKNOWN = iota
 	TOKEN_NUMBER
+	TOKEN_IMAGINARY
+	TOKEN_IDENT
 
 	TOKEN_PLUS
 	TOKEN_MINUS
@@ -10,9 +12,38 @@ const (
 	TOKEN_SLASH
 	TOKEN_PERCENT
 	TOKEN_CARET
+	TOKEN_ASSIGN
+
+	TOKEN_EQUAL
+	TOKEN_NOT_EQUAL
+	TOKEN_LESS
+	TOKEN_LESS_EQUAL
+	TOKEN_GREATER
+	TOKEN_GREATER_EQUAL
+
+	TOKEN_AND
+	TOKEN_OR
+	TOKEN_NOT
+
+	TOKEN_AMPERSAND
+	TOKEN_PIPE
+	TOKEN_XOR
+	TOKEN_TILDE
+	TOKEN_SHIFT_LEFT
+	TOKEN_SHIFT_RIGHT
+
+	TOKEN_QUESTION
+	TOKEN_COLON
+	TOKEN_IF
+
+	TOKEN_IN
+	TOKEN_AS
+	TOKEN_TO
 
 	TOKEN_BRACE_LEFT
 	TOKEN_BRACE_RIGHT
+	TOKEN_SEMICOLON
+	TOKEN_COMMA
 
 	TOKEN_EOF
 )
@@ -20,11 +51,14 @@ const (
 type Token struct {
 	Type int
 	Raw  string
+	Span Span
 }
 
 var TOKENS = map[int]string{
-	TOKEN_UNKNOWN: "TOKEN_UNKNOWN",
-	TOKEN_NUMBER:  "TOKEN_NUMBER",
+	TOKEN_UNKNOWN:   "TOKEN_UNKNOWN",
+	TOKEN_NUMBER:    "TOKEN_NUMBER",
+	TOKEN_IMAGINARY: "TOKEN_IMAGINARY",
+	TOKEN_IDENT:     "TOKEN_IDENT",
 
 	TOKEN_PLUS:     "TOKEN_PLUS",
 	TOKEN_MINUS:    "TOKEN_MINUS",
@@ -32,9 +66,50 @@ var TOKENS = map[int]string{
 	TOKEN_SLASH:    "TOKEN_SLASH",
 	TOKEN_PERCENT:  "TOKEN_PERCENT",
 	TOKEN_CARET:    "TOKEN_CARET",
+	TOKEN_ASSIGN:   "TOKEN_ASSIGN",
+
+	TOKEN_EQUAL:         "TOKEN_EQUAL",
+	TOKEN_NOT_EQUAL:     "TOKEN_NOT_EQUAL",
+	TOKEN_LESS:          "TOKEN_LESS",
+	TOKEN_LESS_EQUAL:    "TOKEN_LESS_EQUAL",
+	TOKEN_GREATER:       "TOKEN_GREATER",
+	TOKEN_GREATER_EQUAL: "TOKEN_GREATER_EQUAL",
+
+	TOKEN_AND: "TOKEN_AND",
+	TOKEN_OR:  "TOKEN_OR",
+	TOKEN_NOT: "TOKEN_NOT",
+
+	TOKEN_AMPERSAND:   "TOKEN_AMPERSAND",
+	TOKEN_PIPE:        "TOKEN_PIPE",
+	TOKEN_XOR:         "TOKEN_XOR",
+	TOKEN_TILDE:       "TOKEN_TILDE",
+	TOKEN_SHIFT_LEFT:  "TOKEN_SHIFT_LEFT",
+	TOKEN_SHIFT_RIGHT: "TOKEN_SHIFT_RIGHT",
+
+	TOKEN_QUESTION: "TOKEN_QUESTION",
+	TOKEN_COLON:    "TOKEN_COLON",
+	TOKEN_IF:       "TOKEN_IF",
+
+	TOKEN_IN: "TOKEN_IN",
+	TOKEN_AS: "TOKEN_AS",
+	TOKEN_TO: "TOKEN_TO",
 
 	TOKEN_BRACE_LEFT:  "TOKEN_BRACE_LEFT",
 	TOKEN_BRACE_RIGHT: "TOKEN_BRACE_RIGHT",
+	TOKEN_SEMICOLON:   "TOKEN_SEMICOLON",
+	TOKEN_COMMA:       "TOKEN_COMMA",
 
 	TOKEN_EOF: "TOKEN_EOF",
 }
+
+// KEYWORDS are identifiers that lex as operators instead.
+var KEYWORDS = map[string]int{
+	"and": TOKEN_AND,
+	"or":  TOKEN_OR,
+	"not": TOKEN_NOT,
+	"xor": TOKEN_XOR,
+	"in":  TOKEN_IN,
+	"as":  TOKEN_AS,
+	"to":  TOKEN_TO,
+	"if":  TOKEN_IF,
+}
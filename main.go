@@ -3,26 +3,95 @@ package main
 import (
 	"bufio"
 	"bytes"
+	"flag"
 	"fmt"
+	"io"
 	"os"
+	"slices"
 	"strings"
 
+	"github.com/Yarik7610/expressive/diagnostic"
 	"github.com/Yarik7610/expressive/lexer"
 	"github.com/Yarik7610/expressive/parser"
 )
 
-func proccessFile(file *os.File) {
-	var b bytes.Buffer
+var (
+	legacyPrecedence = flag.Bool("legacy-precedence", false, "evaluate '^' left to right and apply unary minus before it")
+	mode             = flag.String("mode", "float", "number mode: float, big, rat, decimal, int or complex")
+	prec             = flag.Uint("prec", parser.DefaultPrec, "mantissa bits of numbers in big mode")
+	digits           = flag.Uint("digits", 0, "significant decimal digits of numbers in big mode, overrides -prec")
+	scale            = flag.Uint("scale", 2, "digits after the point of numbers in decimal mode")
+	rounding         = flag.String("rounding", "half-even", "rounding of decimal mode: half-even, half-up, down or ceiling")
+	places           = flag.Int("places", -1, "digits after the point of -format decimal, scientific and engineering, alone it selects decimal")
+	format           = flag.String("format", "shortest", "result format: shortest, decimal, scientific, engineering, hex, bin or oct")
+	units            = flag.String("units", "", "file with unit definitions like 'sprint = 2 week'")
+)
+
+type result struct {
+	column int
+	text   string
+}
+
+func proccessFile(file *os.File, env *parser.Env, opts parser.FormatOptions) {
+	source, err := io.ReadAll(file)
+	if err != nil {
+		panic(fmt.Sprintf("error reading input file: %s", err))
+	}
+
+	tokens, err := lexer.NewLexer(bytes.NewReader(source)).Lex()
+	if err != nil {
+		fmt.Fprint(os.Stderr, diagnostic.Render(string(source), err))
+		os.Exit(1)
+	}
 
-	scanner := bufio.NewScanner(file)
+	//every line of output holds results of expressions starting on the same input line
+	results := make(map[int][]result)
+	report := func(span lexer.Span, text string) {
+		line := span.Start.Line
+		results[line] = append(results[line], result{span.Start.Column, text})
+	}
 
-	for scanner.Scan() {
+	nodes, err := parse(tokens)
+	if err != nil {
+		fmt.Fprint(os.Stderr, diagnostic.Render(string(source), err))
+		for _, syntaxErr := range err.(parser.ErrorList) {
+			report(syntaxErr.Span, syntaxErr.Error())
+		}
+	}
+
+	for _, node := range nodes {
+		value, err := node.Eval(env)
+		if err != nil {
+			fmt.Fprint(os.Stderr, diagnostic.Render(string(source), err))
+			report(node.Span(), err.Error())
+			continue
+		}
+
+		text, err := parser.Format(value, nodeFormat(node, opts))
+		if err != nil {
+			text = err.Error()
+		}
+		report(node.Span(), text)
+	}
+
+	var b bytes.Buffer
+
+	scanner := bufio.NewScanner(bytes.NewReader(source))
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
 		line := strings.TrimSpace(scanner.Text())
 		if line == "" || strings.HasPrefix(line, "#") {
 			b.WriteString(line + "\n")
-		} else {
-			b.WriteString(fmt.Sprintf("%f\n", proccessString(line)))
+			continue
 		}
+
+		lineResults := results[lineNumber]
+		slices.SortStableFunc(lineResults, func(a, b result) int { return a.column - b.column })
+
+		texts := make([]string, 0, len(lineResults))
+		for _, r := range lineResults {
+			texts = append(texts, r.text)
+		}
+		b.WriteString(strings.Join(texts, "; ") + "\n")
 	}
 
 	outputFile, err := os.Create("output.txt")
@@ -37,26 +106,157 @@ func proccessFile(file *os.File) {
 	}
 }
 
-func proccessString(input string) float64 {
+func proccessString(input string, env *parser.Env, opts parser.FormatOptions) (string, error) {
 	l := lexer.NewLexer(strings.NewReader(input))
-	tokens := l.Lex()
+	tokens, err := l.Lex()
+	if err != nil {
+		return "", err
+	}
 
+	nodes, err := parse(tokens)
+	if err != nil {
+		return "", err
+	}
+
+	result, err := parser.Eval(nodes, env)
+	if err != nil {
+		return "", err
+	}
+	return parser.Format(result, nodeFormat(nodes[len(nodes)-1], opts))
+}
+
+// nodeFormat returns the format of a directive like "255 in hex" ending node,
+// or opts without one.
+func nodeFormat(node parser.Node, opts parser.FormatOptions) parser.FormatOptions {
+	if directive, ok := node.(*parser.FormatNode); ok {
+		return directive.Options(opts)
+	}
+	return opts
+}
+
+func parse(tokens []lexer.Token) ([]parser.Node, error) {
 	p := parser.NewParser(tokens)
-	nodes := p.Parse()
+	if *legacyPrecedence {
+		p.Mode |= parser.LegacyPrecedence
+	}
+	if *mode == "int" {
+		p.Mode |= parser.IntegerLiterals
+	}
+	return p.Parse()
+}
+
+func newEnv() (*parser.Env, error) {
+	env := parser.NewEnv()
+	switch *mode {
+	case "float":
+	case "big":
+		bits := *prec
+		if *digits > 0 {
+			bits = parser.DigitsPrec(*digits)
+		}
+		env.SetArithmetic(parser.BigFloatArithmetic{Prec: bits})
+	case "rat":
+		env.SetArithmetic(parser.RationalArithmetic{})
+	case "decimal":
+		r, err := parser.ParseRounding(*rounding)
+		if err != nil {
+			return nil, err
+		}
+		env.SetArithmetic(parser.DecimalArithmetic{Scale: *scale, Rounding: r})
+	case "int":
+		env.SetArithmetic(parser.IntegerArithmetic{})
+	case "complex":
+		env.SetArithmetic(parser.ComplexArithmetic{})
+	default:
+		return nil, fmt.Errorf("unknown mode '%s'", *mode)
+	}
+	return env, nil
+}
+
+func loadUnits(path string, env *parser.Env) {
+	source, err := os.ReadFile(path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %s\n", err)
+		os.Exit(2)
+	}
+	if err := env.LoadUnits(bytes.NewReader(source)); err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %s", path, diagnostic.Render(string(source), err))
+		os.Exit(2)
+	}
+}
+
+func formatOptions() (parser.FormatOptions, error) {
+	notation, err := parser.ParseNotation(*format)
+	if err != nil {
+		return parser.FormatOptions{}, err
+	}
+	if notation == parser.Shortest && *places >= 0 {
+		notation = parser.Fixed
+	}
+	return parser.FormatOptions{Notation: notation, Precision: *places}, nil
+}
+
+// splitArgs separates flags from the input. Flags end at "--" or at the first
+// argument that isn't a registered flag, so an expression like -2^2 or -x+1
+// needs no "--" in front of it.
+func splitArgs(flags *flag.FlagSet, args []string) ([]string, []string) {
+	i := 0
+	for i < len(args) {
+		arg := args[i]
+		if arg == "--" {
+			return args[:i], args[i+1:]
+		}
 
-	return parser.Eval(nodes)
+		if !strings.HasPrefix(arg, "-") {
+			break
+		}
+		//flags are written as -name, --name, -name=value or --name=value
+		name, _, hasValue := strings.Cut(strings.TrimPrefix(arg[1:], "-"), "=")
+		f := flags.Lookup(name)
+		if f == nil {
+			break
+		}
+
+		i++
+		if boolFlag, ok := f.Value.(interface{ IsBoolFlag() bool }); !hasValue && !(ok && boolFlag.IsBoolFlag()) {
+			//the value is the next argument, like in -mode big
+			i++
+		}
+	}
+	return args[:min(i, len(args))], args[min(i, len(args)):]
 }
 
 func main() {
-	if len(os.Args) != 2 {
+	flagArgs, inputs := splitArgs(flag.CommandLine, os.Args[1:])
+	flag.CommandLine.Parse(flagArgs)
+	if len(inputs) != 1 {
 		panic("missing input expression")
 	}
-	input := os.Args[1]
+	input := inputs[0]
+
+	env, err := newEnv()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %s\n", err)
+		os.Exit(2)
+	}
+	opts, err := formatOptions()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %s\n", err)
+		os.Exit(2)
+	}
+	if *units != "" {
+		loadUnits(*units, env)
+	}
 
 	if file, err := os.Open(input); err == nil {
 		defer file.Close()
-		proccessFile(file)
+		proccessFile(file, env, opts)
 	} else {
-		fmt.Println(proccessString(input))
+		text, err := proccessString(input, env, opts)
+		if err != nil {
+			fmt.Fprint(os.Stderr, diagnostic.Render(input, err))
+			os.Exit(1)
+		}
+		fmt.Println(text)
 	}
 }
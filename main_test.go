@@ -0,0 +1,45 @@
+package main
+
+import (
+	"flag"
+	"testing"
+
+	"github.com/Yarik7610/expressive/parser"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSplitArgs(t *testing.T) {
+	tests := []struct {
+		In     []string
+		Flags  []string
+		Inputs []string
+	}{
+		{In: []string{"-2^2"}, Flags: []string{}, Inputs: []string{"-2^2"}},
+		{In: []string{"-mode", "big", "-inf"}, Flags: []string{"-mode", "big"}, Inputs: []string{"-inf"}},
+		{In: []string{"-legacy-precedence", "-x+1"}, Flags: []string{"-legacy-precedence"}, Inputs: []string{"-x+1"}},
+		{In: []string{"--mode=int", "-5 km to m"}, Flags: []string{"--mode=int"}, Inputs: []string{"-5 km to m"}},
+		{In: []string{"--legacy-precedence=false", "-2^2"}, Flags: []string{"--legacy-precedence=false"}, Inputs: []string{"-2^2"}},
+		{In: []string{"--", "-legacy-precedence"}, Flags: []string{}, Inputs: []string{"-legacy-precedence"}},
+		{In: []string{"-mode", "big", "--", "-digits"}, Flags: []string{"-mode", "big"}, Inputs: []string{"-digits"}},
+		{In: []string{"-places", "2", "1/3"}, Flags: []string{"-places", "2"}, Inputs: []string{"1/3"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.In[len(test.In)-1], func(t *testing.T) {
+			flags, inputs := splitArgs(flag.CommandLine, test.In)
+			assert.Equal(t, test.Flags, flags)
+			assert.Equal(t, test.Inputs, inputs)
+		})
+	}
+}
+
+func TestLeadingMinus(t *testing.T) {
+	_, inputs := splitArgs(flag.CommandLine, []string{"-2^2"})
+	opts, err := formatOptions()
+	assert.NoError(t, err)
+
+	out, err := proccessString(inputs[0], parser.NewEnv(), opts)
+	if assert.NoError(t, err) {
+		assert.Equal(t, "-4", out)
+	}
+}
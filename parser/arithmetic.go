@@ -0,0 +1,186 @@
+package parser
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"github.com/Yarik7610/expressive/lexer"
+)
+
+// Arithmetic decides what numbers are in an evaluation mode: how number
+// literals are read and how operators work on them. It's chosen with
+// Env.SetArithmetic, FloatArithmetic is the default.
+//
+// Constants, values passed to Env.Set and results of functions are Number,
+// arithmetics convert them with FromFloat. Functions get their arguments
+// converted back to float64 with Float, unless the arithmetic computes the
+// function on its own numbers.
+type Arithmetic interface {
+	Literal(raw string) (Value, error)
+	FromFloat(f float64) (Value, error)
+	Float(v Value) (float64, error)
+	// Binary applies an arithmetic or a comparison operator to two numbers.
+	Binary(op lexer.Token, left Value, right Value) (Value, error)
+	Negate(v Value) (Value, error)
+}
+
+// nativeFunctions is implemented by arithmetics that compute some built-in
+// functions on their own numbers rather than through float64.
+type nativeFunctions interface {
+	native(name string) (func(args []Value) (Value, error), bool)
+}
+
+// imaginaryLiterals is implemented by arithmetics that can read imaginary
+// literals like 2i, other arithmetics reject them.
+type imaginaryLiterals interface {
+	Imaginary(raw string) (Value, error)
+}
+
+// integerValues is implemented by arithmetics whose numbers work with
+// bitwise operators. integer fails for numbers that aren't integers.
+type integerValues interface {
+	integer(v Value) (*big.Int, error)
+	fromInteger(n *big.Int) (Value, error)
+}
+
+// nativeConstants is implemented by arithmetics with constants of their own,
+// like i of complex numbers. Unlike built-in constants, they are shadowed by
+// variables.
+type nativeConstants interface {
+	constant(name string) (Value, bool)
+}
+
+// preciseConstants is implemented by arithmetics that compute built-in
+// constants like pi at their own precision rather than take float64 ones, or
+// that can't hold them at all and fail.
+type preciseConstants interface {
+	precise(name string) (Value, bool, error)
+}
+
+// FloatArithmetic evaluates with float64 numbers, it's fast but 0.1+0.2 is
+// 0.30000000000000004 and integers above 2^53 lose digits.
+type FloatArithmetic struct{}
+
+func (FloatArithmetic) Literal(raw string) (Value, error) {
+	val, err := floatLiteral(raw)
+	if err != nil {
+		return nil, err
+	}
+	return Number(val), nil
+}
+
+func (FloatArithmetic) FromFloat(f float64) (Value, error) {
+	return Number(f), nil
+}
+
+func (a FloatArithmetic) Float(v Value) (float64, error) {
+	return a.operand(v)
+}
+
+func (a FloatArithmetic) Binary(op lexer.Token, left Value, right Value) (Value, error) {
+	l, err := a.operand(left)
+	if err != nil {
+		return nil, err
+	}
+	r, err := a.operand(right)
+	if err != nil {
+		return nil, err
+	}
+
+	switch op.Type {
+	case lexer.TOKEN_PLUS:
+		return Number(l + r), nil
+	case lexer.TOKEN_MINUS:
+		return Number(l - r), nil
+	case lexer.TOKEN_SLASH:
+		return Number(l / r), nil
+	case lexer.TOKEN_ASTERISK:
+		return Number(l * r), nil
+	case lexer.TOKEN_PERCENT:
+		return Number(math.Mod(l, r)), nil
+	case lexer.TOKEN_CARET:
+		return Number(math.Pow(l, r)), nil
+	case lexer.TOKEN_EQUAL:
+		return Bool(l == r), nil
+	case lexer.TOKEN_NOT_EQUAL:
+		return Bool(l != r), nil
+	case lexer.TOKEN_LESS:
+		return Bool(l < r), nil
+	case lexer.TOKEN_LESS_EQUAL:
+		return Bool(l <= r), nil
+	case lexer.TOKEN_GREATER:
+		return Bool(l > r), nil
+	case lexer.TOKEN_GREATER_EQUAL:
+		return Bool(l >= r), nil
+	default:
+		return nil, fmt.Errorf("undefined operator '%s'", op.Raw)
+	}
+}
+
+func (a FloatArithmetic) Negate(v Value) (Value, error) {
+	n, err := a.operand(v)
+	if err != nil {
+		return nil, err
+	}
+	return Number(-n), nil
+}
+
+func (a FloatArithmetic) integer(v Value) (*big.Int, error) {
+	f, err := a.operand(v)
+	if err != nil {
+		return nil, err
+	}
+	if math.IsInf(f, 0) || f != math.Trunc(f) {
+		return nil, fmt.Errorf("%s isn't an integer", Number(f))
+	}
+	n, _ := new(big.Float).SetFloat64(f).Int(nil)
+	return n, nil
+}
+
+func (FloatArithmetic) fromInteger(n *big.Int) (Value, error) {
+	f, _ := new(big.Float).SetInt(n).Float64()
+	return Number(f), nil
+}
+
+func (FloatArithmetic) operand(v Value) (float64, error) {
+	if n, ok := v.(Number); ok {
+		return float64(n), nil
+	}
+	return 0, fmt.Errorf("can't use %T in float arithmetic", v)
+}
+
+// floatLiteral reads a number literal as the nearest float64.
+func floatLiteral(raw string) (float64, error) {
+	if !isBaseLiteral(raw) {
+		return strconv.ParseFloat(raw, 64)
+	}
+
+	n, err := baseLiteral(raw)
+	if err != nil {
+		return 0, err
+	}
+	f, _ := new(big.Float).SetInt(n).Float64()
+	if math.IsInf(f, 0) {
+		return 0, fmt.Errorf("%s is out of float64 range", raw)
+	}
+	return f, nil
+}
+
+// isBaseLiteral reports whether raw is a hexadecimal, binary or octal literal
+// like 0xFF, 0b101 or 0o755, possibly negated.
+func isBaseLiteral(raw string) bool {
+	raw = strings.TrimPrefix(raw, "-")
+	return len(raw) > 2 && raw[0] == '0' && strings.ContainsRune("xXbBoO", rune(raw[1]))
+}
+
+// baseLiteral reads a literal of isBaseLiteral exactly.
+func baseLiteral(raw string) (*big.Int, error) {
+	n, ok := new(big.Int).SetString(raw, 0)
+	if !ok {
+		return nil, fmt.Errorf("invalid number '%s'", raw)
+	}
+	return n, nil
+}
@@ -0,0 +1,792 @@
+package parser
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"math/bits"
+	"strings"
+
+	"github.com/Yarik7610/expressive/lexer"
+)
+
+// DefaultPrec is the precision of BigFloatArithmetic with zero Prec, it's
+// about 76 decimal digits.
+const DefaultPrec = 256
+
+// BigFloatArithmetic evaluates with big.Float numbers, every result is
+// rounded to Prec bits of mantissa. Built-in functions and constants like pi
+// are computed at that precision too. Numbers that come as float64, like
+// values passed to Env.Set or results of registered functions, have only 53
+// bits, and so have results computed from them. They are printed with as many
+// digits as those bits hold.
+type BigFloatArithmetic struct {
+	Prec uint
+}
+
+// DigitsPrec returns the precision that keeps digits significant decimal
+// digits, with one more digit to spare for rounding errors.
+func DigitsPrec(digits uint) uint {
+	return uint(math.Ceil(float64(digits+1) * math.Log2(10)))
+}
+
+// BigFloat is a number of BigFloatArithmetic.
+type BigFloat struct {
+	f *big.Float
+}
+
+// String prints the digits that the precision of b holds, except the last
+// one, which is likely spoiled by rounding.
+func (b BigFloat) String() string {
+	digits := int(float64(b.f.Prec())*math.Log10(2)) - 1
+	return b.f.Text('g', max(digits, 1))
+}
+
+func (b BigFloat) kind() string {
+	return "number"
+}
+
+// Big returns a copy of the value of b.
+func (b BigFloat) Big() *big.Float {
+	return new(big.Float).Copy(b.f)
+}
+
+func (a BigFloatArithmetic) Literal(raw string) (Value, error) {
+	//base 0 reads prefixed literals like 0xFF too
+	f, _, err := big.ParseFloat(strings.ReplaceAll(raw, "_", ""), 0, a.prec(), big.ToNearestEven)
+	if err != nil {
+		return nil, err
+	}
+	return BigFloat{f}, nil
+}
+
+func (a BigFloatArithmetic) FromFloat(f float64) (Value, error) {
+	if math.IsNaN(f) {
+		return nil, errors.New("nan isn't a big float number")
+	}
+	return BigFloat{new(big.Float).SetFloat64(f)}, nil
+}
+
+func (a BigFloatArithmetic) Float(v Value) (float64, error) {
+	f, err := a.operand(v)
+	if err != nil {
+		return 0, err
+	}
+	result, _ := f.Float64()
+	return result, nil
+}
+
+func (a BigFloatArithmetic) Binary(op lexer.Token, left Value, right Value) (Value, error) {
+	l, err := a.operand(left)
+	if err != nil {
+		return nil, err
+	}
+	r, err := a.operand(right)
+	if err != nil {
+		return nil, err
+	}
+
+	switch op.Type {
+	case lexer.TOKEN_PLUS:
+		return a.compute(func(z *big.Float) error { z.Add(l, r); return nil }, l, r)
+	case lexer.TOKEN_MINUS:
+		return a.compute(func(z *big.Float) error { z.Sub(l, r); return nil }, l, r)
+	case lexer.TOKEN_SLASH:
+		return a.compute(func(z *big.Float) error { z.Quo(l, r); return nil }, l, r)
+	case lexer.TOKEN_ASTERISK:
+		return a.compute(func(z *big.Float) error { z.Mul(l, r); return nil }, l, r)
+	case lexer.TOKEN_PERCENT:
+		return a.compute(func(z *big.Float) error { return bigMod(z, l, r) }, l, r)
+	case lexer.TOKEN_CARET:
+		return a.compute(func(z *big.Float) error { return bigPow(z, l, r) }, l, r)
+	case lexer.TOKEN_EQUAL:
+		return Bool(l.Cmp(r) == 0), nil
+	case lexer.TOKEN_NOT_EQUAL:
+		return Bool(l.Cmp(r) != 0), nil
+	case lexer.TOKEN_LESS:
+		return Bool(l.Cmp(r) < 0), nil
+	case lexer.TOKEN_LESS_EQUAL:
+		return Bool(l.Cmp(r) <= 0), nil
+	case lexer.TOKEN_GREATER:
+		return Bool(l.Cmp(r) > 0), nil
+	case lexer.TOKEN_GREATER_EQUAL:
+		return Bool(l.Cmp(r) >= 0), nil
+	default:
+		return nil, fmt.Errorf("undefined operator '%s'", op.Raw)
+	}
+}
+
+func (a BigFloatArithmetic) Negate(v Value) (Value, error) {
+	f, err := a.operand(v)
+	if err != nil {
+		return nil, err
+	}
+	return a.compute(func(z *big.Float) error { z.Neg(f); return nil }, f)
+}
+
+func (a BigFloatArithmetic) native(name string) (func(args []Value) (Value, error), bool) {
+	fn, ok := bigFloatBuiltins[name]
+	if !ok {
+		return nil, false
+	}
+
+	return func(args []Value) (Value, error) {
+		operands := make([]*big.Float, 0, len(args))
+		for _, arg := range args {
+			f, err := a.operand(arg)
+			if err != nil {
+				return nil, err
+			}
+			operands = append(operands, f)
+		}
+		return a.compute(func(z *big.Float) error { return fn(z, operands...) }, operands...)
+	}, true
+}
+
+func (a BigFloatArithmetic) precise(name string) (Value, bool, error) {
+	fn, ok := bigFloatConstants[name]
+	if !ok {
+		return nil, false, nil
+	}
+	return BigFloat{fn(a.prec())}, true, nil
+}
+
+func (a BigFloatArithmetic) prec() uint {
+	if a.Prec == 0 {
+		return DefaultPrec
+	}
+	return a.Prec
+}
+
+func (a BigFloatArithmetic) integer(v Value) (*big.Int, error) {
+	f, err := a.operand(v)
+	if err != nil {
+		return nil, err
+	}
+	if f.IsInf() || !f.IsInt() {
+		return nil, fmt.Errorf("%s isn't an integer", BigFloat{f})
+	}
+	n, _ := f.Int(nil)
+	return n, nil
+}
+
+func (a BigFloatArithmetic) fromInteger(n *big.Int) (Value, error) {
+	return BigFloat{new(big.Float).SetPrec(a.prec()).SetInt(n)}, nil
+}
+
+func (a BigFloatArithmetic) operand(v Value) (*big.Float, error) {
+	switch v := v.(type) {
+	case BigFloat:
+		return v.f, nil
+	case Number:
+		if math.IsNaN(float64(v)) {
+			return nil, errors.New("nan isn't a big float number")
+		}
+		return new(big.Float).SetFloat64(float64(v)), nil
+	}
+	return nil, fmt.Errorf("can't use %T in big float arithmetic", v)
+}
+
+// compute runs fn on a new number of a's precision, or of the lowest precision
+// of operands, a result can't be more precise than what it's computed from.
+// Infinities hold no digits, their precision doesn't count. big.Float has no
+// NaN, it panics where float64 would give one, compute turns that into an
+// error.
+func (a BigFloatArithmetic) compute(fn func(z *big.Float) error, operands ...*big.Float) (val Value, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			nan, ok := r.(big.ErrNaN)
+			if !ok {
+				panic(r)
+			}
+			val, err = nil, errors.New(nan.Error())
+		}
+	}()
+
+	prec := a.prec()
+	for _, operand := range operands {
+		if !operand.IsInf() {
+			prec = min(prec, operand.Prec())
+		}
+	}
+
+	z := new(big.Float).SetPrec(prec)
+	if err := fn(z); err != nil {
+		return nil, err
+	}
+	return BigFloat{z}, nil
+}
+
+var bigFloatBuiltins = map[string]func(z *big.Float, args ...*big.Float) error{
+	"sqrt": func(z *big.Float, args ...*big.Float) error {
+		if args[0].Sign() < 0 {
+			return errors.New("sqrt of a negative number")
+		}
+		z.Sqrt(args[0])
+		return nil
+	},
+	"exp": func(z *big.Float, args ...*big.Float) error {
+		z.Set(bigExp(args[0], z.Prec()))
+		return nil
+	},
+	"ln": func(z *big.Float, args ...*big.Float) error {
+		if args[0].Sign() < 0 {
+			return errors.New("ln of a negative number")
+		}
+		z.Set(bigLog(args[0], z.Prec()))
+		return nil
+	},
+	"abs": func(z *big.Float, args ...*big.Float) error {
+		z.Abs(args[0])
+		return nil
+	},
+	"trunc": func(z *big.Float, args ...*big.Float) error {
+		bigTrunc(z, args[0])
+		return nil
+	},
+	"floor": func(z *big.Float, args ...*big.Float) error {
+		if bigTrunc(z, args[0]).Cmp(args[0]) > 0 {
+			z.Sub(z, big.NewFloat(1))
+		}
+		return nil
+	},
+	"ceil": func(z *big.Float, args ...*big.Float) error {
+		if bigTrunc(z, args[0]).Cmp(args[0]) < 0 {
+			z.Add(z, big.NewFloat(1))
+		}
+		return nil
+	},
+	"re": func(z *big.Float, args ...*big.Float) error {
+		z.Set(args[0])
+		return nil
+	},
+	"im": func(z *big.Float, args ...*big.Float) error {
+		z.SetInt64(0)
+		return nil
+	},
+	"conj": func(z *big.Float, args ...*big.Float) error {
+		z.Set(args[0])
+		return nil
+	},
+	"min": func(z *big.Float, args ...*big.Float) error {
+		z.Set(args[0])
+		for _, arg := range args[1:] {
+			if arg.Cmp(z) < 0 {
+				z.Set(arg)
+			}
+		}
+		return nil
+	},
+	"max": func(z *big.Float, args ...*big.Float) error {
+		z.Set(args[0])
+		for _, arg := range args[1:] {
+			if arg.Cmp(z) > 0 {
+				z.Set(arg)
+			}
+		}
+		return nil
+	},
+	"round": func(z *big.Float, args ...*big.Float) error {
+		//half away from zero, like math.Round
+		half := new(big.Float).SetMantExp(big.NewFloat(float64(args[0].Sign())), -1)
+		bigTrunc(z, new(big.Float).SetPrec(args[0].Prec()+1).Add(args[0], half))
+		return nil
+	},
+	"cbrt": func(z *big.Float, args ...*big.Float) error {
+		x := args[0]
+		if x.Sign() == 0 || x.IsInf() {
+			z.Set(x)
+			return nil
+		}
+		work := z.Prec() + 64
+		t := bigLog(new(big.Float).Abs(x), work)
+		z.Set(bigExp(t.Quo(t, big.NewFloat(3)), z.Prec()))
+		if x.Sign() < 0 {
+			z.Neg(z)
+		}
+		return nil
+	},
+	"hypot": func(z *big.Float, args ...*big.Float) error {
+		if args[0].IsInf() || args[1].IsInf() {
+			z.SetInf(false)
+			return nil
+		}
+		work := z.Prec() + 64
+		x := new(big.Float).SetPrec(work).Mul(args[0], args[0])
+		y := new(big.Float).SetPrec(work).Mul(args[1], args[1])
+		z.Sqrt(x.Add(x, y))
+		return nil
+	},
+	"log": func(z *big.Float, args ...*big.Float) error {
+		if args[0].Sign() < 0 || args[1].Sign() < 0 {
+			return errors.New("log of a negative number")
+		}
+		work := z.Prec() + 64
+		z.Quo(bigLog(args[0], work), bigLog(args[1], work))
+		return nil
+	},
+	"log10": func(z *big.Float, args ...*big.Float) error {
+		if args[0].Sign() < 0 {
+			return errors.New("log10 of a negative number")
+		}
+		work := z.Prec() + 64
+		z.Quo(bigLog(args[0], work), bigLog(big.NewFloat(10), work))
+		return nil
+	},
+	"log2": func(z *big.Float, args ...*big.Float) error {
+		if args[0].Sign() < 0 {
+			return errors.New("log2 of a negative number")
+		}
+		work := z.Prec() + 64
+		z.Quo(bigLog(args[0], work), bigLn2(work))
+		return nil
+	},
+	"sin": func(z *big.Float, args ...*big.Float) error {
+		sin, _, err := bigSinCos("sin", args[0], z.Prec())
+		if err != nil {
+			return err
+		}
+		z.Set(sin)
+		return nil
+	},
+	"cos": func(z *big.Float, args ...*big.Float) error {
+		_, cos, err := bigSinCos("cos", args[0], z.Prec())
+		if err != nil {
+			return err
+		}
+		z.Set(cos)
+		return nil
+	},
+	"tan": func(z *big.Float, args ...*big.Float) error {
+		sin, cos, err := bigSinCos("tan", args[0], z.Prec()+2)
+		if err != nil {
+			return err
+		}
+		z.Quo(sin, cos)
+		return nil
+	},
+	"asin": func(z *big.Float, args ...*big.Float) error {
+		x := args[0]
+		if x.IsInf() || new(big.Float).Abs(x).Cmp(big.NewFloat(1)) > 0 {
+			return errors.New("asin of a number outside [-1, 1]")
+		}
+		//asin(x) = atan(x / sqrt((1-x)(1+x))), the factors keep all digits of x near 1
+		work := z.Prec() + 64
+		one := big.NewFloat(1)
+		t := new(big.Float).SetPrec(work).Sub(one, x)
+		t.Mul(t, new(big.Float).SetPrec(work).Add(one, x))
+		t.Quo(x, t.Sqrt(t))
+		z.Set(bigAtan(t, z.Prec()))
+		return nil
+	},
+	"acos": func(z *big.Float, args ...*big.Float) error {
+		x := args[0]
+		if x.IsInf() || new(big.Float).Abs(x).Cmp(big.NewFloat(1)) > 0 {
+			return errors.New("acos of a number outside [-1, 1]")
+		}
+		//acos(x) = 2*atan(sqrt((1-x)/(1+x))), which is accurate near 1 where acos is tiny
+		work := z.Prec() + 64
+		one := big.NewFloat(1)
+		t := new(big.Float).SetPrec(work).Sub(one, x)
+		t.Quo(t, new(big.Float).SetPrec(work).Add(one, x))
+		result := bigAtan(t.Sqrt(t), work)
+		z.Set(result.SetMantExp(result, 1))
+		return nil
+	},
+	"atan": func(z *big.Float, args ...*big.Float) error {
+		z.Set(bigAtan(args[0], z.Prec()))
+		return nil
+	},
+	"atan2": func(z *big.Float, args ...*big.Float) error {
+		z.Set(bigAtan2(args[0], args[1], z.Prec()))
+		return nil
+	},
+	"arg": func(z *big.Float, args ...*big.Float) error {
+		z.Set(bigAtan2(new(big.Float), args[0], z.Prec()))
+		return nil
+	},
+}
+
+var bigFloatConstants = map[string]func(prec uint) *big.Float{
+	"pi": bigPi,
+	"e": func(prec uint) *big.Float {
+		return bigExp(big.NewFloat(1), prec)
+	},
+	"tau": func(prec uint) *big.Float {
+		pi := bigPi(prec)
+		return pi.SetMantExp(pi, 1)
+	},
+	"phi": func(prec uint) *big.Float {
+		//(1 + sqrt(5)) / 2
+		phi := new(big.Float).SetPrec(prec + 64).SetInt64(5)
+		phi.Sqrt(phi).Add(phi, big.NewFloat(1))
+		return phi.SetMantExp(phi, -1).SetPrec(prec)
+	},
+}
+
+func bigTrunc(z *big.Float, x *big.Float) *big.Float {
+	if x.IsInf() {
+		return z.Set(x)
+	}
+	i, _ := x.Int(nil)
+	return z.SetInt(i)
+}
+
+// bigMod is the remainder of truncated division, the same as math.Mod. It's
+// computed on exact rationals, so the quotient is never rounded to a wrong
+// integer.
+func bigMod(z *big.Float, x *big.Float, y *big.Float) error {
+	switch {
+	case y.Sign() == 0:
+		return errors.New("modulo by zero")
+	case x.IsInf():
+		return errors.New("modulo of infinity")
+	case y.IsInf():
+		z.Set(x)
+		return nil
+	}
+
+	xr, _ := x.Rat(nil)
+	yr, _ := y.Rat(nil)
+	q := new(big.Rat).Quo(xr, yr)
+	trunc := new(big.Int).Quo(q.Num(), q.Denom())
+
+	r := new(big.Rat).Mul(yr, new(big.Rat).SetInt(trunc))
+	z.SetRat(r.Sub(xr, r))
+	return nil
+}
+
+// bigPow follows the special cases of math.Pow, except that a negative base
+// with a fractional exponent is an error instead of NaN.
+func bigPow(z *big.Float, x *big.Float, y *big.Float) error {
+	if n, acc := y.Int64(); acc == big.Exact && !y.IsInf() {
+		bigPowInt(z, x, n)
+		return nil
+	}
+
+	switch {
+	case y.IsInf():
+		abs := new(big.Float).Abs(x)
+		switch cmp := abs.Cmp(big.NewFloat(1)); {
+		case cmp == 0:
+			z.SetInt64(1)
+		case (cmp < 0) == (y.Sign() > 0):
+			z.SetInt64(0)
+		default:
+			z.SetInf(false)
+		}
+	case x.Sign() < 0:
+		return errors.New("negative number to a fractional power")
+	case x.Sign() == 0:
+		z.SetInt64(0)
+		if y.Sign() < 0 {
+			z.SetInf(false)
+		}
+	case x.IsInf():
+		z.SetInf(false)
+		if y.Sign() < 0 {
+			z.SetInt64(0)
+		}
+	default:
+		//x^y = exp(y*ln(x)), an error in y*ln(x) grows by its magnitude in the result
+		prec := z.Prec() + 64
+		t := new(big.Float).SetPrec(prec).Mul(y, bigLog(x, prec))
+		if exp := t.MantExp(nil); exp > 0 {
+			prec += uint(exp)
+			t = new(big.Float).SetPrec(prec).Mul(y, bigLog(x, prec))
+		}
+		z.Set(bigExp(t, z.Prec()))
+	}
+	return nil
+}
+
+// bigPowInt computes x^n by squaring. Every squaring doubles the rounding
+// error, so the work is done with as many extra bits as n has.
+func bigPowInt(z *big.Float, x *big.Float, n int64) {
+	abs := uint64(n)
+	if n < 0 {
+		abs = -abs
+	}
+
+	prec := z.Prec() + uint(bits.Len64(abs)) + 16
+	result := new(big.Float).SetPrec(prec).SetInt64(1)
+	base := new(big.Float).SetPrec(prec).Set(x)
+	for ; abs > 0; abs >>= 1 {
+		if abs&1 == 1 {
+			result.Mul(result, base)
+		}
+		if abs > 1 {
+			base.Mul(base, base)
+		}
+	}
+
+	if n < 0 {
+		result.Quo(new(big.Float).SetInt64(1), result)
+	}
+	z.Set(result)
+}
+
+// bigExp returns e^x rounded to prec bits.
+func bigExp(x *big.Float, prec uint) *big.Float {
+	switch {
+	case x.IsInf() && x.Sign() > 0:
+		return new(big.Float).SetInf(false)
+	case x.IsInf():
+		return new(big.Float)
+	//e^x doesn't fit into the exponent range of big.Float anyway
+	case x.Cmp(big.NewFloat(1<<32)) > 0:
+		return new(big.Float).SetInf(false)
+	case x.Cmp(big.NewFloat(-1<<32)) < 0:
+		return new(big.Float)
+	}
+
+	const halvings = 16
+	work := prec + 64
+
+	//x = n*ln(2) + r with |r| < ln(2), so e^x = 2^n * e^r
+	ln2 := bigLn2(work + 34)
+	n, _ := new(big.Float).Quo(x, ln2).Int64()
+	r := new(big.Float).SetPrec(work + 34).SetInt64(n)
+	r.Sub(x, r.Mul(r, ln2))
+	r.SetPrec(work)
+
+	//e^r = (e^(r/2^halvings))^(2^halvings), the Taylor series converges fast for tiny arguments
+	r.SetMantExp(r, -halvings)
+	sum := new(big.Float).SetPrec(work).SetInt64(1)
+	term := new(big.Float).SetPrec(work).SetInt64(1)
+	for k := int64(1); ; k++ {
+		term.Mul(term, r)
+		term.Quo(term, new(big.Float).SetInt64(k))
+		if term.Sign() == 0 || term.MantExp(nil) < sum.MantExp(nil)-int(work) {
+			break
+		}
+		sum.Add(sum, term)
+	}
+	for range halvings {
+		sum.Mul(sum, sum)
+	}
+
+	//SetMantExp keeps the precision of sum, it's rounded afterwards
+	return sum.SetMantExp(sum, int(n)).SetPrec(prec)
+}
+
+// bigLog returns ln(x) rounded to prec bits for x >= 0.
+func bigLog(x *big.Float, prec uint) *big.Float {
+	switch {
+	case x.Sign() == 0:
+		return new(big.Float).SetInf(true)
+	case x.IsInf():
+		return new(big.Float).SetInf(false)
+	}
+
+	work := prec + 64
+
+	//x = m * 2^k with m in [1/sqrt(2), sqrt(2)), so ln(x) = ln(m) + k*ln(2)
+	m := new(big.Float)
+	k := x.MantExp(m)
+	m.SetPrec(work)
+	if m.Cmp(big.NewFloat(math.Sqrt2/2)) < 0 {
+		m.SetMantExp(m, 1)
+		k--
+	}
+
+	one := big.NewFloat(1)
+	z := new(big.Float).SetPrec(work).Sub(m, one)
+	z.Quo(z, new(big.Float).SetPrec(work).Add(m, one))
+	result := logSeries(z, work)
+
+	if k != 0 {
+		ln2 := bigLn2(work + uint(bits.Len(uint(max(k, -k)))))
+		result.Add(result, ln2.Mul(ln2, new(big.Float).SetInt64(int64(k))))
+	}
+	return result.SetPrec(prec)
+}
+
+func bigLn2(prec uint) *big.Float {
+	return logSeries(new(big.Float).SetPrec(prec).Quo(big.NewFloat(1), big.NewFloat(3)), prec)
+}
+
+// logSeries returns ln((1+z)/(1-z)) = 2*(z + z^3/3 + z^5/5 + ...) for small |z|.
+func logSeries(z *big.Float, prec uint) *big.Float {
+	z2 := new(big.Float).SetPrec(prec).Mul(z, z)
+	power := new(big.Float).SetPrec(prec).Set(z)
+	sum := new(big.Float).SetPrec(prec).Set(z)
+	term := new(big.Float).SetPrec(prec)
+	for n := int64(3); sum.Sign() != 0; n += 2 {
+		power.Mul(power, z2)
+		term.Quo(power, new(big.Float).SetInt64(n))
+		if term.Sign() == 0 || term.MantExp(nil) < sum.MantExp(nil)-int(prec) {
+			break
+		}
+		sum.Add(sum, term)
+	}
+	return sum.Mul(sum, big.NewFloat(2))
+}
+
+// bigPi returns pi rounded to prec bits, by Machin's formula
+// pi = 16*atan(1/5) - 4*atan(1/239).
+func bigPi(prec uint) *big.Float {
+	work := prec + 64
+	one := big.NewFloat(1)
+	a := atanSeries(new(big.Float).SetPrec(work).Quo(one, big.NewFloat(5)), work)
+	b := atanSeries(new(big.Float).SetPrec(work).Quo(one, big.NewFloat(239)), work)
+	a.Mul(a, big.NewFloat(16))
+	b.Mul(b, big.NewFloat(4))
+	return a.Sub(a, b).SetPrec(prec)
+}
+
+// bigAtan returns atan(x) rounded to prec bits.
+func bigAtan(x *big.Float, prec uint) *big.Float {
+	if x.IsInf() {
+		pi := bigPi(prec)
+		pi.SetMantExp(pi, -1)
+		if x.Sign() < 0 {
+			pi.Neg(pi)
+		}
+		return pi
+	}
+
+	const halvings = 8
+	work := prec + 64
+
+	//atan(x) = pi/2 - atan(1/x) for x > 1
+	y := new(big.Float).SetPrec(work).Abs(x)
+	inverted := y.Cmp(big.NewFloat(1)) > 0
+	if inverted {
+		y.Quo(big.NewFloat(1), y)
+	}
+
+	//atan(y) = 2*atan(y / (1 + sqrt(1 + y^2))), the series converges fast for tiny arguments
+	one := big.NewFloat(1)
+	for range halvings {
+		t := new(big.Float).SetPrec(work).Mul(y, y)
+		t.Add(t, one)
+		t.Sqrt(t).Add(t, one)
+		y.Quo(y, t)
+	}
+	result := atanSeries(y, work)
+	result.SetMantExp(result, halvings)
+
+	if inverted {
+		halfPi := bigPi(work)
+		halfPi.SetMantExp(halfPi, -1)
+		result.Sub(halfPi, result)
+	}
+	if x.Sign() < 0 {
+		result.Neg(result)
+	}
+	return result.SetPrec(prec)
+}
+
+// bigAtan2 returns the angle of the point (x, y) rounded to prec bits, with
+// the special cases of math.Atan2.
+func bigAtan2(y *big.Float, x *big.Float, prec uint) *big.Float {
+	work := prec + 64
+	pi := bigPi(work)
+
+	var result *big.Float
+	switch {
+	case x.IsInf() && y.IsInf():
+		//pi/4 or 3*pi/4
+		result = new(big.Float).SetPrec(work).SetMantExp(pi, -2)
+		if x.Sign() < 0 {
+			result.Mul(result, big.NewFloat(3))
+		}
+	case x.Sign() == 0 && y.Sign() == 0:
+		result = new(big.Float)
+		if x.Signbit() {
+			result = pi
+		}
+	case x.Sign() == 0:
+		result = pi.SetMantExp(pi, -1)
+	default:
+		t := new(big.Float).SetPrec(work).Quo(y, x)
+		result = bigAtan(t.Abs(t), work)
+		if x.Sign() < 0 {
+			result.Sub(pi, result)
+		}
+	}
+
+	if y.Signbit() {
+		result.Neg(result)
+	}
+	return result.SetPrec(prec)
+}
+
+// atanSeries returns atan(z) = z - z^3/3 + z^5/5 - ... for small |z|.
+func atanSeries(z *big.Float, prec uint) *big.Float {
+	z2 := new(big.Float).SetPrec(prec).Mul(z, z)
+	z2.Neg(z2)
+	power := new(big.Float).SetPrec(prec).Set(z)
+	sum := new(big.Float).SetPrec(prec).Set(z)
+	term := new(big.Float).SetPrec(prec)
+	for n := int64(3); sum.Sign() != 0; n += 2 {
+		power.Mul(power, z2)
+		term.Quo(power, new(big.Float).SetInt64(n))
+		if term.Sign() == 0 || term.MantExp(nil) < sum.MantExp(nil)-int(prec) {
+			break
+		}
+		sum.Add(sum, term)
+	}
+	return sum
+}
+
+// maxTrigExp bounds arguments of sin, cos and tan to 2^maxTrigExp. Reducing
+// x modulo pi/2 takes pi with as many more bits as x has before the point.
+const maxTrigExp = 1 << 12
+
+// bigSinCos returns sin(x) and cos(x) rounded to prec bits, name is the
+// function they are computed for.
+func bigSinCos(name string, x *big.Float, prec uint) (*big.Float, *big.Float, error) {
+	if x.IsInf() {
+		return nil, nil, fmt.Errorf("%s of infinity", name)
+	}
+	exp := x.MantExp(nil)
+	if exp > maxTrigExp {
+		return nil, nil, fmt.Errorf("%s of a number above 2^%d", name, maxTrigExp)
+	}
+
+	//x = k*pi/2 + r with |r| <= pi/4, k modulo 4 picks the quadrant
+	work := prec + 64 + uint(max(exp, 0))
+	halfPi := bigPi(work)
+	halfPi.SetMantExp(halfPi, -1)
+	q := new(big.Float).SetPrec(work).Quo(x, halfPi)
+	q.Add(q, new(big.Float).SetMantExp(big.NewFloat(float64(q.Sign())), -1))
+	k, _ := q.Int(nil)
+	r := new(big.Float).SetPrec(work).SetInt(k)
+	r.Sub(x, r.Mul(r, halfPi))
+
+	sin, cos := sinCosSeries(r, work)
+	switch new(big.Int).Mod(k, big.NewInt(4)).Int64() {
+	case 1:
+		sin, cos = cos, sin.Neg(sin)
+	case 2:
+		sin, cos = sin.Neg(sin), cos.Neg(cos)
+	case 3:
+		sin, cos = cos.Neg(cos), sin
+	}
+	return sin.SetPrec(prec), cos.SetPrec(prec), nil
+}
+
+// sinCosSeries returns sin(r) and cos(r) by their Taylor series, for |r| <= pi/4.
+func sinCosSeries(r *big.Float, prec uint) (*big.Float, *big.Float) {
+	r2 := new(big.Float).SetPrec(prec).Mul(r, r)
+	r2.Neg(r2)
+
+	series := func(first *big.Float, n int64) *big.Float {
+		sum := new(big.Float).SetPrec(prec).Set(first)
+		term := new(big.Float).SetPrec(prec).Set(first)
+		for ; sum.Sign() != 0; n += 2 {
+			term.Mul(term, r2)
+			term.Quo(term, new(big.Float).SetInt64(n*(n+1)))
+			if term.Sign() == 0 || term.MantExp(nil) < sum.MantExp(nil)-int(prec) {
+				break
+			}
+			sum.Add(sum, term)
+		}
+		return sum
+	}
+	//sin(r) = r - r^3/3! + r^5/5! - ..., cos(r) = 1 - r^2/2! + r^4/4! - ...
+	return series(r, 2), series(big.NewFloat(1), 1)
+}
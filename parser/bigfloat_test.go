@@ -0,0 +1,125 @@
+package parser
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBigFloatArithmetic(t *testing.T) {
+	nonErrorTests := []struct {
+		In  string
+		Out string
+	}{
+		{In: "0.1 + 0.2", Out: "0.3"},
+		{In: "2^64 + 1 > 2^64", Out: "true"},
+		{In: "2^100 + 1", Out: "1267650600228229401496703205377"},
+		{In: "0x10_0000_0000_0000_0000_0000_0001", Out: "1267650600228229401496703205377"},
+		{In: "1/3", Out: "0.3333333333333333333333333333333333333333"},
+		{In: "2^0.5", Out: "1.41421356237309504880168872420969807857"},
+		{In: "sqrt(2)", Out: "1.41421356237309504880168872420969807857"},
+		{In: "exp(1)", Out: "2.718281828459045235360287471352662497757"},
+		{In: "ln(10)", Out: "2.302585092994045684017991454684364207601"},
+		{In: "exp(-1000)", Out: "5.075958897549456765291809479574336919306e-435"},
+		{In: "2^-3 + 2^3^2", Out: "512.125"},
+		{In: "7.5 % 2", Out: "1.5"},
+		{In: "-7 % 3", Out: "-1"},
+		{In: "(2^80 + 1) % 10", Out: "7"},
+		{In: "max(2^80 + 1, 3) - 2^80", Out: "1"},
+		{In: "floor(-2.5) + ceil(2.5) + trunc(-2.5)", Out: "-2"},
+		{In: "1_000.5e-1_0", Out: "1.0005e-07"},
+		{In: "1/0", Out: "+Inf"},
+		{In: "sin(1)", Out: "0.8414709848078965066525023216302989996226"},
+		{In: "cos(1) ^ 2 + sin(1) ^ 2", Out: "1"},
+		{In: "tan(-1)", Out: "-1.557407724654902230506974807458360173087"},
+		{In: "sin(10^30)", Out: "-0.0901169019121380580303864289529873302744"},
+		{In: "atan(1) * 4", Out: "3.141592653589793238462643383279502884197"},
+		{In: "asin(-1) + acos(-1)", Out: "1.570796326794896619231321691639751442099"},
+		{In: "atan2(-1, -1)", Out: "-2.356194490192344928846982537459627163148"},
+		{In: "pi", Out: "3.141592653589793238462643383279502884197"},
+		{In: "pi * 1", Out: "3.141592653589793238462643383279502884197"},
+		{In: "e", Out: "2.718281828459045235360287471352662497757"},
+		{In: "tau - 2 * pi", Out: "0"},
+		{In: "phi", Out: "1.61803398874989484820458683436563811772"},
+		{In: "cbrt(-27)", Out: "-3"},
+		{In: "log2(1024) + log10(1000) + log(81, 3)", Out: "17"},
+		{In: "hypot(5, 12)", Out: "13"},
+		{In: "round(-2.5) + round(2.4999)", Out: "-1"},
+	}
+
+	errorTests := []struct {
+		In      string
+		Message string
+	}{
+		{In: "0/0", Message: "division of zero by zero or infinity by infinity"},
+		{In: "5 % 0", Message: "modulo by zero"},
+		{In: "(-8)^(1/3)", Message: "negative number to a fractional power"},
+		{In: "sqrt(-1)", Message: "sqrt of a negative number"},
+		{In: "nan + 1", Message: "'nan': nan isn't a big float number"},
+		{In: "asin(2)", Message: "asin of a number outside [-1, 1]"},
+		{In: "sin(1/0)", Message: "sin of infinity"},
+		{In: "cos(2^5000)", Message: "cos of a number above 2^4096"},
+		{In: "log2(-1)", Message: "log2 of a negative number"},
+	}
+
+	newEnv := func() *Env {
+		env := NewEnv()
+		env.SetArithmetic(BigFloatArithmetic{Prec: DigitsPrec(40)})
+		return env
+	}
+
+	for _, test := range nonErrorTests {
+		t.Run(test.In, func(t *testing.T) {
+			out, err := Eval(parse(t, test.In), newEnv())
+			assert.NoError(t, err)
+			assert.Equal(t, test.Out, out.String())
+		})
+	}
+
+	for _, test := range errorTests {
+		t.Run(test.In, func(t *testing.T) {
+			_, err := Eval(parse(t, test.In), newEnv())
+			var evalErr *EvalError
+			if assert.ErrorAs(t, err, &evalErr) {
+				assert.Equal(t, test.Message, evalErr.Message)
+			}
+		})
+	}
+}
+
+func TestBigFloatPrecision(t *testing.T) {
+	env := NewEnv()
+	env.SetArithmetic(BigFloatArithmetic{})
+	out, err := Eval(parse(t, "2^255 + 1"), env)
+	assert.NoError(t, err)
+	assert.Equal(t, uint(DefaultPrec), out.(BigFloat).Big().Prec())
+	assert.Equal(t, "57896044618658097711785492504343953926634992332820282019728792003956564819969", out.(BigFloat).Big().Text('f', 0))
+
+	env.SetArithmetic(BigFloatArithmetic{Prec: 24})
+	out, err = Eval(parse(t, "2^30 + 1"), env)
+	assert.NoError(t, err)
+	assert.Equal(t, "1.07374e+09", out.String())
+
+	env.Set("x", Number(0.5))
+	out, err = Eval(parse(t, "x * 4"), env)
+	assert.NoError(t, err)
+	assert.Equal(t, "2", out.String())
+
+	//float64 numbers hold 53 bits, results computed from them aren't printed with more digits
+	env.SetArithmetic(BigFloatArithmetic{})
+	env.Set("y", Number(0.1))
+	env.RegisterFunc("third", func(args ...float64) (float64, error) { return args[0] / 3, nil }, 1)
+	out, err = Eval(parse(t, "y * 3"), env)
+	assert.NoError(t, err)
+	assert.Equal(t, "0.3", out.String())
+	assert.Equal(t, uint(53), out.(BigFloat).Big().Prec())
+
+	out, err = Eval(parse(t, "third(1) * 1"), env)
+	assert.NoError(t, err)
+	assert.Equal(t, "0.33333333333333", out.String())
+
+	env.RegisterConst("pi", 3)
+	out, err = Eval(parse(t, "pi / 2"), env)
+	assert.NoError(t, err)
+	assert.Equal(t, "1.5", out.String())
+}
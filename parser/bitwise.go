@@ -0,0 +1,87 @@
+package parser
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/Yarik7610/expressive/lexer"
+)
+
+// bitwiseOp applies "&", "|", "xor", "<<" or ">>" to integers of any
+// arithmetic implementing integerValues. Negative numbers behave as in two's
+// complement of unlimited width, so -1 & 0xFF is 0xFF and -8 >> 1 is -4.
+func bitwiseOp(arith Arithmetic, op lexer.Token, left Value, right Value) (Value, error) {
+	ints, ok := arith.(integerValues)
+	if !ok {
+		return nil, fmt.Errorf("undefined operator '%s'", op.Raw)
+	}
+	x, err := bitwiseOperand(ints, op, left)
+	if err != nil {
+		return nil, err
+	}
+	y, err := bitwiseOperand(ints, op, right)
+	if err != nil {
+		return nil, err
+	}
+
+	z := new(big.Int)
+	switch op.Type {
+	case lexer.TOKEN_AMPERSAND:
+		z.And(x, y)
+	case lexer.TOKEN_PIPE:
+		z.Or(x, y)
+	case lexer.TOKEN_XOR:
+		z.Xor(x, y)
+	case lexer.TOKEN_SHIFT_LEFT, lexer.TOKEN_SHIFT_RIGHT:
+		if err := shift(z, op, x, y); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("undefined operator '%s'", op.Raw)
+	}
+	return ints.fromInteger(z)
+}
+
+// bitwiseNot applies "~", which is -x-1 for integers.
+func bitwiseNot(arith Arithmetic, op lexer.Token, v Value) (Value, error) {
+	ints, ok := arith.(integerValues)
+	if !ok {
+		return nil, fmt.Errorf("undefined operator '%s'", op.Raw)
+	}
+	x, err := bitwiseOperand(ints, op, v)
+	if err != nil {
+		return nil, err
+	}
+	return ints.fromInteger(x.Not(x))
+}
+
+func bitwiseOperand(ints integerValues, op lexer.Token, v Value) (*big.Int, error) {
+	n, err := ints.integer(v)
+	if err != nil {
+		return nil, fmt.Errorf("'%s' needs integers, %s", op.Raw, err)
+	}
+	return n, nil
+}
+
+// shift sets z to x shifted by n bits. Left shifts are limited like the size
+// of rational numbers, right shifts by more bits than x has give 0 or -1.
+func shift(z *big.Int, op lexer.Token, x *big.Int, n *big.Int) error {
+	if n.Sign() < 0 {
+		return fmt.Errorf("negative shift count %s", n)
+	}
+
+	if op.Type == lexer.TOKEN_SHIFT_LEFT {
+		if n.Cmp(big.NewInt(maxRationalBits)) > 0 {
+			return fmt.Errorf("shift count %s is too large", n)
+		}
+		z.Lsh(x, uint(n.Uint64()))
+		return nil
+	}
+
+	count := uint(x.BitLen())
+	if n.Cmp(big.NewInt(int64(count))) < 0 {
+		count = uint(n.Uint64())
+	}
+	z.Rsh(x, count)
+	return nil
+}
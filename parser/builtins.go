@@ -0,0 +1,93 @@
+package parser
+
+import (
+	"fmt"
+	"math"
+)
+
+type function struct {
+	minArgs int
+	//maxArgs is -1 for functions taking any number of arguments
+	maxArgs int
+	call    func(args ...float64) (float64, error)
+}
+
+func (f function) checkArity(name string, count int) error {
+	switch {
+	case f.minArgs == f.maxArgs && count != f.minArgs:
+		return fmt.Errorf("%s expects %s, got %d", name, plural(f.minArgs, "argument"), count)
+	case count < f.minArgs:
+		return fmt.Errorf("%s expects at least %s, got %d", name, plural(f.minArgs, "argument"), count)
+	case f.maxArgs >= 0 && count > f.maxArgs:
+		return fmt.Errorf("%s expects at most %s, got %d", name, plural(f.maxArgs, "argument"), count)
+	}
+	return nil
+}
+
+func plural(count int, noun string) string {
+	if count == 1 {
+		return fmt.Sprintf("%d %s", count, noun)
+	}
+	return fmt.Sprintf("%d %ss", count, noun)
+}
+
+var constants = map[string]float64{
+	"pi":  math.Pi,
+	"e":   math.E,
+	"tau": 2 * math.Pi,
+	"phi": math.Phi,
+	"inf": math.Inf(1),
+	"nan": math.NaN(),
+}
+
+var builtins = map[string]function{
+	"sin":   unary(math.Sin),
+	"cos":   unary(math.Cos),
+	"tan":   unary(math.Tan),
+	"asin":  unary(math.Asin),
+	"acos":  unary(math.Acos),
+	"atan":  unary(math.Atan),
+	"atan2": binary(math.Atan2),
+	"sqrt":  unary(math.Sqrt),
+	"cbrt":  unary(math.Cbrt),
+	"exp":   unary(math.Exp),
+	"ln":    unary(math.Log),
+	"log":   binary(func(x, base float64) float64 { return math.Log(x) / math.Log(base) }),
+	"log10": unary(math.Log10),
+	"log2":  unary(math.Log2),
+	"abs":   unary(math.Abs),
+	"floor": unary(math.Floor),
+	"ceil":  unary(math.Ceil),
+	"round": unary(math.Round),
+	"trunc": unary(math.Trunc),
+	"min":   variadic(math.Min),
+	"max":   variadic(math.Max),
+	"hypot": binary(math.Hypot),
+	"re":    unary(func(x float64) float64 { return x }),
+	"im":    unary(func(x float64) float64 { return 0 }),
+	"conj":  unary(func(x float64) float64 { return x }),
+	"arg":   unary(func(x float64) float64 { return math.Atan2(0, x) }),
+}
+
+func unary(fn func(float64) float64) function {
+	return function{1, 1, func(args ...float64) (float64, error) {
+		return fn(args[0]), nil
+	}}
+}
+
+func binary(fn func(float64, float64) float64) function {
+	return function{2, 2, func(args ...float64) (float64, error) {
+		return fn(args[0], args[1]), nil
+	}}
+}
+
+// variadic folds one or more arguments with fn.
+func variadic(fn func(float64, float64) float64) function {
+	return function{1, -1, func(args ...float64) (float64, error) {
+		result := args[0]
+		for _, arg := range args[1:] {
+			result = fn(result, arg)
+		}
+		return result, nil
+	}}
+}
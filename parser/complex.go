@@ -0,0 +1,251 @@
+package parser
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"math/cmplx"
+	"strconv"
+	"strings"
+
+	"github.com/Yarik7610/expressive/lexer"
+)
+
+// ComplexArithmetic evaluates with complex128 numbers. Imaginary literals
+// like 2i and the imaginary unit i are allowed, i can be shadowed by a
+// variable. Functions give complex results where real ones don't exist, so
+// sqrt(-1) is i and ln(-1) is pi*i, but real arguments are computed with
+// the real functions, which keeps results like sqrt(4) exact.
+//
+// Ordering comparisons and "%" need real operands, and so do the functions
+// without complex versions, like floor, min or atan2.
+type ComplexArithmetic struct{}
+
+// Complex is a number of ComplexArithmetic.
+type Complex complex128
+
+// String prints c like 1+2i, numbers without an imaginary part are printed
+// as real ones.
+func (c Complex) String() string {
+	re, im := real(c), imag(c)
+	if im == 0 {
+		return formatComplexPart(re)
+	}
+	if re == 0 {
+		return formatComplexPart(im) + "i"
+	}
+
+	sign := "+"
+	if math.Signbit(im) {
+		sign = "-"
+		im = -im
+	}
+	return formatComplexPart(re) + sign + strings.TrimPrefix(formatComplexPart(im), "+") + "i"
+}
+
+func formatComplexPart(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+func (c Complex) kind() string {
+	return "number"
+}
+
+func (ComplexArithmetic) Literal(raw string) (Value, error) {
+	f, err := floatLiteral(raw)
+	if err != nil {
+		return nil, err
+	}
+	return Complex(complex(f, 0)), nil
+}
+
+func (ComplexArithmetic) Imaginary(raw string) (Value, error) {
+	f, err := floatLiteral(strings.TrimSuffix(raw, "i"))
+	if err != nil {
+		return nil, err
+	}
+	return Complex(complex(0, f)), nil
+}
+
+func (ComplexArithmetic) FromFloat(f float64) (Value, error) {
+	return Complex(complex(f, 0)), nil
+}
+
+func (a ComplexArithmetic) Float(v Value) (float64, error) {
+	c, err := a.operand(v)
+	if err != nil {
+		return 0, err
+	}
+	if imag(c) != 0 {
+		return 0, fmt.Errorf("%s isn't a real number", Complex(c))
+	}
+	return real(c), nil
+}
+
+func (a ComplexArithmetic) Binary(op lexer.Token, left Value, right Value) (Value, error) {
+	l, err := a.operand(left)
+	if err != nil {
+		return nil, err
+	}
+	r, err := a.operand(right)
+	if err != nil {
+		return nil, err
+	}
+
+	//real operands behave like in float mode, except that "^" may have a complex result
+	if imag(l) == 0 && imag(r) == 0 && op.Type != lexer.TOKEN_CARET {
+		val, err := FloatArithmetic{}.Binary(op, Number(real(l)), Number(real(r)))
+		if number, ok := val.(Number); ok {
+			return Complex(complex(float64(number), 0)), nil
+		}
+		return val, err
+	}
+
+	switch op.Type {
+	case lexer.TOKEN_PLUS:
+		return Complex(l + r), nil
+	case lexer.TOKEN_MINUS:
+		return Complex(l - r), nil
+	case lexer.TOKEN_ASTERISK:
+		return Complex(l * r), nil
+	case lexer.TOKEN_SLASH:
+		return Complex(l / r), nil
+	case lexer.TOKEN_CARET:
+		return Complex(complexPow(l, r)), nil
+	case lexer.TOKEN_EQUAL:
+		return Bool(l == r), nil
+	case lexer.TOKEN_NOT_EQUAL:
+		return Bool(l != r), nil
+	}
+	return nil, fmt.Errorf("can't apply '%s' to complex numbers %s and %s", op.Raw, Complex(l), Complex(r))
+}
+
+func (a ComplexArithmetic) Negate(v Value) (Value, error) {
+	c, err := a.operand(v)
+	if err != nil {
+		return nil, err
+	}
+	return Complex(-c), nil
+}
+
+func (a ComplexArithmetic) native(name string) (func(args []Value) (Value, error), bool) {
+	fn, ok := complexBuiltins[name]
+	if !ok {
+		return nil, false
+	}
+
+	return func(args []Value) (Value, error) {
+		operands := make([]complex128, 0, len(args))
+		for _, arg := range args {
+			c, err := a.operand(arg)
+			if err != nil {
+				return nil, err
+			}
+			operands = append(operands, c)
+		}
+		return Complex(fn(operands...)), nil
+	}, true
+}
+
+func (ComplexArithmetic) constant(name string) (Value, bool) {
+	if name == "i" {
+		return Complex(1i), true
+	}
+	return nil, false
+}
+
+// integer needs a real integer, bitwise operators work on the real part
+// through FloatArithmetic.
+func (a ComplexArithmetic) integer(v Value) (*big.Int, error) {
+	c, err := a.operand(v)
+	if err != nil {
+		return nil, err
+	}
+	if imag(c) != 0 {
+		return nil, fmt.Errorf("%s isn't an integer", Complex(c))
+	}
+	return FloatArithmetic{}.integer(Number(real(c)))
+}
+
+func (ComplexArithmetic) fromInteger(n *big.Int) (Value, error) {
+	f, _ := new(big.Float).SetInt(n).Float64()
+	return Complex(complex(f, 0)), nil
+}
+
+func (ComplexArithmetic) operand(v Value) (complex128, error) {
+	switch v := v.(type) {
+	case Complex:
+		return complex128(v), nil
+	case Number:
+		return complex(float64(v), 0), nil
+	}
+	return 0, fmt.Errorf("can't use %T in complex arithmetic", v)
+}
+
+var complexBuiltins = map[string]func(args ...complex128) complex128{
+	"sqrt":  complexUnary(math.Sqrt, cmplx.Sqrt),
+	"exp":   complexUnary(math.Exp, cmplx.Exp),
+	"ln":    complexUnary(math.Log, cmplx.Log),
+	"log10": complexUnary(math.Log10, cmplx.Log10),
+	"log2":  complexUnary(math.Log2, func(x complex128) complex128 { return cmplx.Log(x) / math.Ln2 }),
+	"sin":   complexUnary(math.Sin, cmplx.Sin),
+	"cos":   complexUnary(math.Cos, cmplx.Cos),
+	"tan":   complexUnary(math.Tan, cmplx.Tan),
+	"asin":  complexUnary(math.Asin, cmplx.Asin),
+	"acos":  complexUnary(math.Acos, cmplx.Acos),
+	"atan":  complexUnary(math.Atan, cmplx.Atan),
+	"log": func(args ...complex128) complex128 {
+		x, base := args[0], args[1]
+		if imag(x) == 0 && imag(base) == 0 && real(x) >= 0 && real(base) >= 0 {
+			return complex(math.Log(real(x))/math.Log(real(base)), 0)
+		}
+		return cmplx.Log(x) / cmplx.Log(base)
+	},
+	"abs":  func(args ...complex128) complex128 { return complex(cmplx.Abs(args[0]), 0) },
+	"re":   func(args ...complex128) complex128 { return complex(real(args[0]), 0) },
+	"im":   func(args ...complex128) complex128 { return complex(imag(args[0]), 0) },
+	"conj": func(args ...complex128) complex128 { return cmplx.Conj(args[0]) },
+	"arg":  func(args ...complex128) complex128 { return complex(cmplx.Phase(args[0]), 0) },
+}
+
+// complexUnary uses realFn for real arguments, unless its result is NaN
+// because the argument is out of its domain, and complexFn otherwise.
+func complexUnary(realFn func(float64) float64, complexFn func(complex128) complex128) func(args ...complex128) complex128 {
+	return func(args ...complex128) complex128 {
+		if x := args[0]; imag(x) == 0 {
+			if result := realFn(real(x)); !math.IsNaN(result) || math.IsNaN(real(x)) {
+				return complex(result, 0)
+			}
+		}
+		return complexFn(args[0])
+	}
+}
+
+// complexPow multiplies for integer exponents, cmplx.Pow goes through
+// logarithms and would give 2^3 as 7.999999999999998. Real powers that have
+// real results are computed by math.Pow.
+func complexPow(x complex128, y complex128) complex128 {
+	n := real(y)
+	if imag(y) == 0 && n == math.Trunc(n) && math.Abs(n) <= 1024 {
+		if imag(x) == 0 {
+			return complex(math.Pow(real(x), n), 0)
+		}
+
+		result, base := complex128(1), x
+		for k := int(math.Abs(n)); k > 0; k >>= 1 {
+			if k&1 == 1 {
+				result *= base
+			}
+			base *= base
+		}
+		if n < 0 {
+			return 1 / result
+		}
+		return result
+	}
+
+	if imag(x) == 0 && imag(y) == 0 && real(x) >= 0 {
+		return complex(math.Pow(real(x), real(y)), 0)
+	}
+	return cmplx.Pow(x, y)
+}
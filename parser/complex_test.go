@@ -0,0 +1,95 @@
+package parser
+
+import (
+	"math"
+	"testing"
+
+	"github.com/Yarik7610/expressive/lexer"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestComplexArithmetic(t *testing.T) {
+	nonErrorTests := []struct {
+		In  string
+		Out Value
+	}{
+		{In: "(1+2i)*(3-i)", Out: Complex(5 + 5i)},
+		{In: "sqrt(-1)", Out: Complex(1i)},
+		{In: "sqrt(4)", Out: Complex(2)},
+		{In: "i^2", Out: Complex(-1)},
+		{In: "(1+i)^2", Out: Complex(2i)},
+		{In: "2^3", Out: Complex(8)},
+		{In: "0b1 + 0o17i", Out: Complex(1 + 15i)},
+		{In: "(1+i)^-2", Out: Complex(-0.5i)},
+		{In: "(3+4i)/(1+2i)", Out: Complex(2.2 - 0.4i)},
+		{In: "ln(-1)", Out: Complex(complex(0, math.Pi))},
+		{In: "abs(3+4i)", Out: Complex(5)},
+		{In: "conj(1+2i)", Out: Complex(1 - 2i)},
+		{In: "re(1+2i) + im(1+2i)", Out: Complex(3)},
+		{In: "1+i == i+1", Out: Bool(true)},
+		{In: "i = 3; i*2", Out: Complex(6)},
+		{In: "1/0", Out: Complex(complex(math.Inf(1), 0))},
+	}
+
+	errorTests := []struct {
+		In      string
+		Message string
+		Span    lexer.Span
+	}{
+		{
+			In:      "1i < 2",
+			Message: "can't apply '<' to complex numbers 1i and 2",
+			Span:    lexer.Span{Start: lexer.Position{Offset: 0, Line: 1, Column: 1}, End: lexer.Position{Offset: 6, Line: 1, Column: 7}},
+		},
+		{
+			In:      "2i % 1",
+			Message: "can't apply '%' to complex numbers 2i and 1",
+			Span:    lexer.Span{Start: lexer.Position{Offset: 0, Line: 1, Column: 1}, End: lexer.Position{Offset: 6, Line: 1, Column: 7}},
+		},
+		{
+			In:      "floor(1+i)",
+			Message: "1+1i isn't a real number",
+			Span:    lexer.Span{Start: lexer.Position{Offset: 6, Line: 1, Column: 7}, End: lexer.Position{Offset: 9, Line: 1, Column: 10}},
+		},
+	}
+
+	newEnv := func() *Env {
+		env := NewEnv()
+		env.SetArithmetic(ComplexArithmetic{})
+		return env
+	}
+
+	for _, test := range nonErrorTests {
+		t.Run(test.In, func(t *testing.T) {
+			out, err := Eval(parse(t, test.In), newEnv())
+			assert.NoError(t, err)
+			assert.Equal(t, test.Out, out)
+		})
+	}
+
+	for _, test := range errorTests {
+		t.Run(test.In, func(t *testing.T) {
+			_, err := Eval(parse(t, test.In), newEnv())
+			var evalErr *EvalError
+			if assert.ErrorAs(t, err, &evalErr) {
+				assert.Equal(t, test.Message, evalErr.Message)
+				assert.Equal(t, test.Span, evalErr.Span)
+			}
+		})
+	}
+
+	t.Run("imaginary literal without complex mode", func(t *testing.T) {
+		_, err := Eval(parse(t, "1 + 2i"), NewEnv())
+		var evalErr *EvalError
+		if assert.ErrorAs(t, err, &evalErr) {
+			assert.Equal(t, "imaginary number '2i' needs complex mode", evalErr.Message)
+		}
+	})
+}
+
+func TestComplexString(t *testing.T) {
+	assert.Equal(t, "1+2i", Complex(1+2i).String())
+	assert.Equal(t, "1-2i", Complex(1-2i).String())
+	assert.Equal(t, "-2i", Complex(-2i).String())
+	assert.Equal(t, "-1", Complex(-1).String())
+}
@@ -0,0 +1,303 @@
+package parser
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strings"
+
+	"github.com/Yarik7610/expressive/lexer"
+)
+
+// Rounding is how DecimalArithmetic drops digits beyond its scale.
+type Rounding int
+
+const (
+	// RoundHalfEven rounds to the nearest value, ties to an even last digit.
+	RoundHalfEven Rounding = iota
+	// RoundHalfUp rounds to the nearest value, ties away from zero.
+	RoundHalfUp
+	// RoundDown rounds towards zero.
+	RoundDown
+	// RoundCeiling rounds towards positive infinity.
+	RoundCeiling
+)
+
+var roundingNames = map[Rounding]string{
+	RoundHalfEven: "half-even",
+	RoundHalfUp:   "half-up",
+	RoundDown:     "down",
+	RoundCeiling:  "ceiling",
+}
+
+func (r Rounding) String() string {
+	if name, ok := roundingNames[r]; ok {
+		return name
+	}
+	return fmt.Sprintf("Rounding(%d)", int(r))
+}
+
+// ParseRounding returns the rounding mode called name: "half-even",
+// "half-up", "down" or "ceiling".
+func ParseRounding(name string) (Rounding, error) {
+	for rounding, n := range roundingNames {
+		if n == name {
+			return rounding, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown rounding mode '%s'", name)
+}
+
+// DecimalArithmetic evaluates with base-10 numbers of Scale digits after the
+// point, like SQL NUMERIC. Literals and the result of every operation are
+// rounded to the scale with Rounding, so 1/3*3 is 0.99 at scale 2.
+//
+// Operations are exact before rounding, except fractional powers, which are
+// computed with enough extra digits to round correctly in all but the
+// closest ties. abs, floor, ceil, round, trunc, min, max, re, im and conj
+// are exact too, other functions are computed in float64 and then rounded.
+type DecimalArithmetic struct {
+	Scale    uint
+	Rounding Rounding
+}
+
+// Decimal is a number of DecimalArithmetic, its value is Unscaled / 10^Scale.
+type Decimal struct {
+	unscaled *big.Int
+	scale    uint
+}
+
+// String prints all digits of d's scale, 0.1 at scale 2 is 0.10.
+func (d Decimal) String() string {
+	digits := new(big.Int).Abs(d.unscaled).String()
+	if len(digits) <= int(d.scale) {
+		digits = strings.Repeat("0", int(d.scale)-len(digits)+1) + digits
+	}
+
+	s := digits
+	if d.scale > 0 {
+		point := len(digits) - int(d.scale)
+		s = digits[:point] + "." + digits[point:]
+	}
+	if d.unscaled.Sign() < 0 {
+		s = "-" + s
+	}
+	return s
+}
+
+func (d Decimal) kind() string {
+	return "number"
+}
+
+// Unscaled returns a copy of the integer that d holds scaled by 10^Scale.
+func (d Decimal) Unscaled() *big.Int {
+	return new(big.Int).Set(d.unscaled)
+}
+
+// Scale returns the number of digits after the point.
+func (d Decimal) Scale() uint {
+	return d.scale
+}
+
+func (d Decimal) rat() *big.Rat {
+	return new(big.Rat).SetFrac(d.unscaled, pow10(d.scale))
+}
+
+func (a DecimalArithmetic) Literal(raw string) (Value, error) {
+	r, err := ratLiteral(raw)
+	if err != nil {
+		return nil, err
+	}
+	return a.round(r), nil
+}
+
+func (a DecimalArithmetic) FromFloat(f float64) (Value, error) {
+	r, err := ratFromFloat(f)
+	if err != nil {
+		return nil, err
+	}
+	return a.round(r), nil
+}
+
+func (a DecimalArithmetic) Float(v Value) (float64, error) {
+	r, err := a.operand(v)
+	if err != nil {
+		return 0, err
+	}
+	f, _ := r.Float64()
+	return f, nil
+}
+
+func (a DecimalArithmetic) Binary(op lexer.Token, left Value, right Value) (Value, error) {
+	l, err := a.operand(left)
+	if err != nil {
+		return nil, err
+	}
+	r, err := a.operand(right)
+	if err != nil {
+		return nil, err
+	}
+
+	z := new(big.Rat)
+	switch op.Type {
+	case lexer.TOKEN_PLUS:
+		z.Add(l, r)
+	case lexer.TOKEN_MINUS:
+		z.Sub(l, r)
+	case lexer.TOKEN_ASTERISK:
+		z.Mul(l, r)
+	case lexer.TOKEN_SLASH:
+		if r.Sign() == 0 {
+			return nil, errors.New("division by zero")
+		}
+		z.Quo(l, r)
+	case lexer.TOKEN_PERCENT:
+		if r.Sign() == 0 {
+			return nil, errors.New("modulo by zero")
+		}
+		ratMod(z, l, r)
+	case lexer.TOKEN_CARET:
+		if err := a.pow(z, l, r); err != nil {
+			return nil, err
+		}
+	case lexer.TOKEN_EQUAL:
+		return Bool(l.Cmp(r) == 0), nil
+	case lexer.TOKEN_NOT_EQUAL:
+		return Bool(l.Cmp(r) != 0), nil
+	case lexer.TOKEN_LESS:
+		return Bool(l.Cmp(r) < 0), nil
+	case lexer.TOKEN_LESS_EQUAL:
+		return Bool(l.Cmp(r) <= 0), nil
+	case lexer.TOKEN_GREATER:
+		return Bool(l.Cmp(r) > 0), nil
+	case lexer.TOKEN_GREATER_EQUAL:
+		return Bool(l.Cmp(r) >= 0), nil
+	default:
+		return nil, fmt.Errorf("undefined operator '%s'", op.Raw)
+	}
+	return a.round(z), nil
+}
+
+func (a DecimalArithmetic) Negate(v Value) (Value, error) {
+	r, err := a.operand(v)
+	if err != nil {
+		return nil, err
+	}
+	return a.round(r.Neg(r)), nil
+}
+
+func (a DecimalArithmetic) native(name string) (func(args []Value) (Value, error), bool) {
+	//sqrt of rationals is exact or an error, decimals round it like other functions instead
+	fn, ok := rationalBuiltins[name]
+	if !ok || name == "sqrt" {
+		return nil, false
+	}
+
+	return func(args []Value) (Value, error) {
+		operands := make([]*big.Rat, 0, len(args))
+		for _, arg := range args {
+			r, err := a.operand(arg)
+			if err != nil {
+				return nil, err
+			}
+			operands = append(operands, r)
+		}
+
+		z := new(big.Rat)
+		if err := fn(z, operands...); err != nil {
+			return nil, err
+		}
+		return a.round(z), nil
+	}, true
+}
+
+func (a DecimalArithmetic) integer(v Value) (*big.Int, error) {
+	r, err := a.operand(v)
+	if err != nil {
+		return nil, err
+	}
+	if !r.IsInt() {
+		return nil, fmt.Errorf("%s isn't an integer", a.round(r))
+	}
+	return r.Num(), nil
+}
+
+func (a DecimalArithmetic) fromInteger(n *big.Int) (Value, error) {
+	return a.round(new(big.Rat).SetInt(n)), nil
+}
+
+// operand returns a new exact value of v, which the caller may modify.
+func (a DecimalArithmetic) operand(v Value) (*big.Rat, error) {
+	switch v := v.(type) {
+	case Decimal:
+		return v.rat(), nil
+	case Number:
+		return ratFromFloat(float64(v))
+	}
+	return nil, fmt.Errorf("can't use %T in decimal arithmetic", v)
+}
+
+// pow is exact for integer exponents. Fractional ones go through big.Float
+// with as many bits as the integer part and the scale of the result need,
+// plus 64 to spare.
+func (a DecimalArithmetic) pow(z *big.Rat, x *big.Rat, y *big.Rat) error {
+	if y.IsInt() {
+		return ratPow(z, x, y)
+	}
+
+	scaleBits := uint(math.Ceil(float64(a.Scale)*math.Log2(10))) + 64
+	fx, fy := new(big.Float).SetRat(x), new(big.Float).SetRat(y)
+	for prec, intBits := scaleBits, 0; ; {
+		result := BigFloatArithmetic{Prec: prec}
+		val, err := result.compute(func(f *big.Float) error { return bigPow(f, fx, fy) })
+		if err != nil {
+			return err
+		}
+
+		f := val.(BigFloat).f
+		if f.IsInf() {
+			return errors.New("result of '^' is too large for a decimal number")
+		}
+		if exp := f.MantExp(nil); exp > intBits {
+			intBits = exp
+			prec = scaleBits + uint(exp)
+			continue
+		}
+		f.Rat(z)
+		return nil
+	}
+}
+
+// round converts r to a's scale.
+func (a DecimalArithmetic) round(r *big.Rat) Decimal {
+	scaled := new(big.Rat).Mul(r, new(big.Rat).SetInt(pow10(a.Scale)))
+	q, rem := new(big.Int).QuoRem(scaled.Num(), scaled.Denom(), new(big.Int))
+
+	//rem has the sign of r, twice its absolute value is compared to the denominator for ties
+	away := false
+	if rem.Sign() != 0 {
+		half := new(big.Int).Abs(rem)
+		half.Lsh(half, 1)
+		cmp := half.Cmp(scaled.Denom())
+
+		switch a.Rounding {
+		case RoundHalfEven:
+			away = cmp > 0 || (cmp == 0 && q.Bit(0) == 1)
+		case RoundHalfUp:
+			away = cmp >= 0
+		case RoundDown:
+		case RoundCeiling:
+			away = r.Sign() > 0
+		}
+	}
+
+	if away {
+		q.Add(q, big.NewInt(int64(r.Sign())))
+	}
+	return Decimal{q, a.Scale}
+}
+
+func pow10(n uint) *big.Int {
+	return new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(n)), nil)
+}
@@ -0,0 +1,94 @@
+package parser
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDecimalArithmetic(t *testing.T) {
+	nonErrorTests := []struct {
+		In       string
+		Rounding Rounding
+		Out      string
+	}{
+		{In: "0.1 + 0.2", Out: "0.30"},
+		{In: "0.1 + 0.2 == 0.3", Out: "true"},
+		{In: "1/3 * 3", Out: "0.99"},
+		{In: "7", Out: "7.00"},
+		{In: "0o17 / 0b10", Out: "7.50"},
+		{In: "10 % 3", Out: "1.00"},
+		{In: "1.05^10", Out: "1.63"},
+		{In: "2^0.5", Out: "1.41"},
+		{In: "sqrt(2)", Out: "1.41"},
+		{In: "round(-2.5) + floor(2.99)", Out: "-1.00"},
+		{In: "2.345", Rounding: RoundHalfEven, Out: "2.34"},
+		{In: "2.355", Rounding: RoundHalfEven, Out: "2.36"},
+		{In: "2.345", Rounding: RoundHalfUp, Out: "2.35"},
+		{In: "-2.345", Rounding: RoundHalfUp, Out: "-2.35"},
+		{In: "2.349", Rounding: RoundDown, Out: "2.34"},
+		{In: "-2.349", Rounding: RoundDown, Out: "-2.34"},
+		{In: "2.341", Rounding: RoundCeiling, Out: "2.35"},
+		{In: "-2.349", Rounding: RoundCeiling, Out: "-2.34"},
+		{In: "-0.001", Rounding: RoundCeiling, Out: "0.00"},
+		{In: "1/3 * 3", Rounding: RoundCeiling, Out: "1.02"},
+	}
+
+	errorTests := []struct {
+		In      string
+		Message string
+	}{
+		{In: "1/0", Message: "division by zero"},
+		{In: "1 % 0", Message: "modulo by zero"},
+		{In: "(-8)^(1/3)", Message: "negative number to a fractional power"},
+		{In: "nan", Message: "'nan': NaN isn't a rational number"},
+	}
+
+	for _, test := range nonErrorTests {
+		t.Run(test.Rounding.String()+" "+test.In, func(t *testing.T) {
+			env := NewEnv()
+			env.SetArithmetic(DecimalArithmetic{Scale: 2, Rounding: test.Rounding})
+			out, err := Eval(parse(t, test.In), env)
+			assert.NoError(t, err)
+			assert.Equal(t, test.Out, out.String())
+		})
+	}
+
+	for _, test := range errorTests {
+		t.Run(test.In, func(t *testing.T) {
+			env := NewEnv()
+			env.SetArithmetic(DecimalArithmetic{Scale: 2})
+			_, err := Eval(parse(t, test.In), env)
+			var evalErr *EvalError
+			if assert.ErrorAs(t, err, &evalErr) {
+				assert.Equal(t, test.Message, evalErr.Message)
+			}
+		})
+	}
+}
+
+func TestDecimalScale(t *testing.T) {
+	env := NewEnv()
+	env.SetArithmetic(DecimalArithmetic{})
+	out, err := Eval(parse(t, "7 / 2"), env)
+	assert.NoError(t, err)
+	assert.Equal(t, "4", out.String())
+
+	env.SetArithmetic(DecimalArithmetic{Scale: 4, Rounding: RoundDown})
+	out, err = Eval(parse(t, "-2 / 3"), env)
+	assert.NoError(t, err)
+	assert.Equal(t, "-0.6666", out.String())
+	assert.Equal(t, int64(-6666), out.(Decimal).Unscaled().Int64())
+	assert.Equal(t, uint(4), out.(Decimal).Scale())
+}
+
+func TestParseRounding(t *testing.T) {
+	for _, rounding := range []Rounding{RoundHalfEven, RoundHalfUp, RoundDown, RoundCeiling} {
+		parsed, err := ParseRounding(rounding.String())
+		assert.NoError(t, err)
+		assert.Equal(t, rounding, parsed)
+	}
+
+	_, err := ParseRounding("up")
+	assert.EqualError(t, err, "unknown rounding mode 'up'")
+}
@@ -0,0 +1,241 @@
+package parser
+
+import (
+	"fmt"
+	"io"
+	"maps"
+	"math/big"
+	"strings"
+
+	"github.com/Yarik7610/expressive/lexer"
+)
+
+// Variadic is the arity of registered functions that take any number of
+// arguments.
+const Variadic = -1
+
+// Env holds variables, constants, functions and units that expressions are
+// evaluated with. Every Env has its own registry, so evaluators with different functions
+// can run side by side, but a single Env must not be used by several goroutines
+// at once. A nil *Env can be read from and behaves like an empty environment.
+type Env struct {
+	vars   map[string]Value
+	consts map[string]float64
+	funcs  map[string]function
+	units  map[string]unitDef
+	arith  Arithmetic
+}
+
+func NewEnv() *Env {
+	return &Env{
+		vars:   make(map[string]Value),
+		consts: make(map[string]float64),
+		funcs:  make(map[string]function),
+		units:  make(map[string]unitDef),
+	}
+}
+
+// SetArithmetic switches the evaluation mode, for example to
+// BigFloatArithmetic. Values computed before the switch may not mix with
+// the new numbers.
+func (e *Env) SetArithmetic(arith Arithmetic) {
+	e.arith = arith
+}
+
+func (e *Env) arithmetic() Arithmetic {
+	if e == nil || e.arith == nil {
+		return FloatArithmetic{}
+	}
+	return e.arith
+}
+
+func (e *Env) Set(name string, value Value) {
+	e.vars[name] = value
+}
+
+// Get returns the constant or, if there is no such constant, the variable
+// called name. Registered constants shadow built-in ones like pi.
+func (e *Env) Get(name string) (Value, bool) {
+	if e != nil {
+		if value, ok := e.consts[name]; ok {
+			return Number(value), true
+		}
+	}
+	if value, ok := constants[name]; ok {
+		return Number(value), true
+	}
+	if e == nil {
+		return nil, false
+	}
+	value, ok := e.vars[name]
+	return value, ok
+}
+
+// RegisterConst binds name to a value that expressions can read but can't
+// assign to.
+func (e *Env) RegisterConst(name string, value float64) {
+	e.consts[name] = value
+}
+
+// RegisterFunc makes fn callable from expressions. Calls with a number of
+// arguments other than arity are rejected before fn is called, unless arity
+// is Variadic. Registered functions take precedence over built-in ones.
+func (e *Env) RegisterFunc(name string, fn func(args ...float64) (float64, error), arity int) {
+	if arity < 0 {
+		e.funcs[name] = function{0, -1, fn}
+		return
+	}
+	e.funcs[name] = function{arity, arity, fn}
+}
+
+func (e *Env) isConst(name string) bool {
+	if _, ok := constants[name]; ok {
+		return true
+	}
+	if e == nil {
+		return false
+	}
+	_, ok := e.consts[name]
+	return ok
+}
+
+func (e *Env) assign(name string, value Value) error {
+	if e.isConst(name) {
+		return fmt.Errorf("can't assign to constant '%s'", name)
+	}
+	e.Set(name, value)
+	return nil
+}
+
+func (e *Env) function(name string) (function, bool) {
+	if e != nil {
+		if fn, ok := e.funcs[name]; ok {
+			return fn, true
+		}
+	}
+	fn, ok := builtins[name]
+	return fn, ok
+}
+
+// native returns the arithmetic's own version of a built-in function, if it
+// has one and the function isn't overridden by a registered one.
+func (e *Env) native(name string) (func(args []Value) (Value, error), bool) {
+	if e != nil {
+		if _, ok := e.funcs[name]; ok {
+			return nil, false
+		}
+	}
+	if arith, ok := e.arithmetic().(nativeFunctions); ok {
+		return arith.native(name)
+	}
+	return nil, false
+}
+
+// constant returns a constant of the arithmetic in use, like i of complex
+// numbers.
+func (e *Env) constant(name string) (Value, bool) {
+	if arith, ok := e.arithmetic().(nativeConstants); ok {
+		return arith.constant(name)
+	}
+	return nil, false
+}
+
+// preciseConstant returns a built-in constant like pi computed by the
+// arithmetic in use, unless a registered constant shadows it.
+func (e *Env) preciseConstant(name string) (Value, bool, error) {
+	if e != nil {
+		if _, ok := e.consts[name]; ok {
+			return nil, false, nil
+		}
+	}
+	if arith, ok := e.arithmetic().(preciseConstants); ok {
+		return arith.precise(name)
+	}
+	return nil, false, nil
+}
+
+// DefineUnit adds a unit defined like a line of a units file, "2 week" or
+// "1.08 USD". An empty definition makes a new base unit. The definition can
+// only use units that are already known, and redefining a unit must keep its
+// dimension: t can become a short ton, but not a unit of time.
+func (e *Env) DefineUnit(name string, definition string) error {
+	if !isUnitName(name) {
+		return fmt.Errorf("'%s' can't be a unit name", name)
+	}
+
+	def := unitDef{big.NewRat(1, 1), dimension{name: 1}}
+	if strings.TrimSpace(definition) != "" {
+		factor, expr, err := parseUnitDefinition(definition)
+		if err != nil {
+			return err
+		}
+		unit, err := evalUnit(e, expr)
+		if err != nil {
+			return err
+		}
+		def = unitDef{factor.Mul(factor, unit.factor()), unit.dim()}
+	}
+
+	if err := e.checkRedefinition(name, def); err != nil {
+		return err
+	}
+	e.units[name] = def
+	return nil
+}
+
+// LoadUnits reads a units file, see DefineUnit for the form of definitions:
+//
+//	# a new base unit
+//	point
+//	sprint = 2 week
+//	velocity = 1 point/sprint
+//
+// Units of the file can be used before their definition, but not in terms of
+// themselves. The first broken definition is returned as a *lexer.LexError,
+// *SyntaxError or *EvalError, and then no unit of the file is added.
+func (e *Env) LoadUnits(r io.Reader) error {
+	tokens, err := lexer.NewLexer(r).Lex()
+	if err != nil {
+		return err
+	}
+	statements, err := parseUnitFile(tokens)
+	if err != nil {
+		return err
+	}
+
+	l := unitLoader{env: e, statements: make(map[string]*unitStatement), defs: make(map[string]unitDef)}
+	for _, statement := range statements {
+		if first, ok := l.statements[statement.name.Raw]; ok {
+			return &EvalError{fmt.Sprintf("unit '%s' is already defined at %s", statement.name.Raw, first.name.Span.Start), statement.name.Span}
+		}
+		l.statements[statement.name.Raw] = statement
+	}
+	for _, statement := range statements {
+		if _, err := l.resolve(statement); err != nil {
+			return err
+		}
+	}
+
+	maps.Copy(e.units, l.defs)
+	return nil
+}
+
+// checkRedefinition allows defining a unit that already exists only with the
+// same dimension, so quantities computed before keep their meaning.
+func (e *Env) checkRedefinition(name string, def unitDef) error {
+	if old, ok := e.unit(name); ok && !old.dim.equal(def.dim) {
+		return fmt.Errorf("'%s' has dimension %s, it can't be redefined with dimension %s", name, describeDimension(old.dim), describeDimension(def.dim))
+	}
+	return nil
+}
+
+// unit returns the unit called name, defined units come before built-in ones,
+// which may have an SI prefix like km.
+func (e *Env) unit(name string) (unitDef, bool) {
+	if e != nil {
+		if def, ok := e.units[name]; ok {
+			return def, true
+		}
+	}
+	return builtinUnits.lookup(name)
+}
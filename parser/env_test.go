@@ -0,0 +1,88 @@
+package parser
+
+import (
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEnvRegistry(t *testing.T) {
+	env := NewEnv()
+	env.RegisterConst("vat", 0.2)
+	env.RegisterFunc("tax", func(args ...float64) (float64, error) {
+		if args[0] < 0 {
+			return 0, errors.New("tax of a negative amount")
+		}
+		return args[0] * 0.1, nil
+	}, 1)
+	env.RegisterFunc("sum", func(args ...float64) (float64, error) {
+		total := 0.0
+		for _, arg := range args {
+			total += arg
+		}
+		return total, nil
+	}, Variadic)
+	env.RegisterFunc("sqrt", func(args ...float64) (float64, error) {
+		return -1, nil
+	}, 1)
+
+	out, err := Eval(parse(t, "tax(100) * (1 + vat) + sum() + sum(1, 2, 3) + sqrt(4)"), env)
+	assert.NoError(t, err)
+	assert.InDelta(t, 17, float64(out.(Number)), 1e-12)
+
+	errorTests := []struct {
+		In      string
+		Message string
+	}{
+		{In: "tax(1, 2)", Message: "tax expects 1 argument, got 2"},
+		{In: "tax(-1)", Message: "tax of a negative amount"},
+		{In: "vat = 0.3", Message: "can't assign to constant 'vat'"},
+	}
+
+	for _, test := range errorTests {
+		t.Run(test.In, func(t *testing.T) {
+			_, err := Eval(parse(t, test.In), env)
+			var evalErr *EvalError
+			if assert.ErrorAs(t, err, &evalErr) {
+				assert.Equal(t, test.Message, evalErr.Message)
+			}
+		})
+	}
+}
+
+func TestEnvIsolation(t *testing.T) {
+	nodes := parse(t, "rate(10) + base")
+
+	var wg sync.WaitGroup
+	results := make([]Value, 2)
+	for i := range results {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			env := NewEnv()
+			factor := float64(i + 1)
+			env.RegisterConst("base", factor)
+			env.RegisterFunc("rate", func(args ...float64) (float64, error) {
+				return args[0] * factor, nil
+			}, 1)
+
+			for range 100 {
+				out, err := Eval(nodes, env)
+				assert.NoError(t, err)
+				results[i] = out
+			}
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, []Value{Number(11), Number(22)}, results)
+
+	_, err := Eval(nodes, NewEnv())
+	var evalErr *EvalError
+	if assert.ErrorAs(t, err, &evalErr) {
+		assert.Equal(t, "undefined function 'rate'", evalErr.Message)
+	}
+}
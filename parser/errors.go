@@ -0,0 +1,55 @@
+package parser
+
+import (
+	"fmt"
+
+	"github.com/Yarik7610/expressive/lexer"
+)
+
+// SyntaxError is returned by Parse when tokens don't match the grammar.
+type SyntaxError struct {
+	Message string
+	Span    lexer.Span
+}
+
+func (e *SyntaxError) Error() string {
+	if !e.Span.Start.IsValid() {
+		return fmt.Sprintf("parser: %s", e.Message)
+	}
+	return fmt.Sprintf("parser: %s: %s", e.Span.Start, e.Message)
+}
+
+// ErrorList is returned by Parse when one or more expressions are broken.
+type ErrorList []*SyntaxError
+
+func (l ErrorList) Error() string {
+	switch len(l) {
+	case 0:
+		return "no errors"
+	case 1:
+		return l[0].Error()
+	default:
+		return fmt.Sprintf("%s (and %d more errors)", l[0], len(l)-1)
+	}
+}
+
+func (l ErrorList) Unwrap() []error {
+	errs := make([]error, len(l))
+	for i, err := range l {
+		errs[i] = err
+	}
+	return errs
+}
+
+// EvalError is returned when a syntactically valid tree can't be evaluated.
+type EvalError struct {
+	Message string
+	Span    lexer.Span
+}
+
+func (e *EvalError) Error() string {
+	if !e.Span.Start.IsValid() {
+		return fmt.Sprintf("eval: %s", e.Message)
+	}
+	return fmt.Sprintf("eval: %s: %s", e.Span.Start, e.Message)
+}
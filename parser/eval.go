@@ -1,10 +1,24 @@
 package parser
 
-func Eval(nodes []Node) float64 {
+// Eval evaluates nodes one by one, so assignments made by earlier nodes are
+// visible to later ones, and returns the value of the last node. A nil env is
+// replaced by an empty one.
+func Eval(nodes []Node, env *Env) (Value, error) {
 	if len(nodes) == 0 {
-		panic("eval: no nodes provided")
+		return nil, &EvalError{Message: "no nodes provided"}
+	}
+	if env == nil {
+		env = NewEnv()
+	}
+
+	var result Value
+	for _, node := range nodes {
+		value, err := node.Eval(env)
+		if err != nil {
+			return nil, err
+		}
+		result = value
 	}
 
-	root := nodes[0]
-	return root.Eval()
+	return result, nil
 }
@@ -1,6 +1,8 @@
 package parser
 
 import (
+	"math"
+	"strings"
 	"testing"
 
 	"github.com/Yarik7610/expressive/lexer"
@@ -8,7 +10,7 @@ import (
 )
 
 func TestEval(t *testing.T) {
-	nonPanicTests := []struct {
+	nonErrorTests := []struct {
 		Name string
 		In   []Node
 		Out  float64
@@ -66,10 +68,398 @@ func TestEval(t *testing.T) {
 		},
 	}
 
-	for _, test := range nonPanicTests {
+	errorTests := []struct {
+		Name string
+		In   []Node
+	}{
+		{
+			Name: "no nodes",
+			In:   []Node{},
+		},
+		{
+			Name: "number out of range",
+			In: []Node{
+				&BinaryNode{
+					Token: lexer.Token{Type: lexer.TOKEN_PLUS, Raw: "+"},
+					Left:  &NumberNode{Token: lexer.Token{Type: lexer.TOKEN_NUMBER, Raw: "1"}},
+					Right: &NumberNode{Token: lexer.Token{Type: lexer.TOKEN_NUMBER, Raw: "1e999"}},
+				},
+			},
+		},
+	}
+
+	for _, test := range nonErrorTests {
+		t.Run(test.Name, func(t *testing.T) {
+			out, err := Eval(test.In, nil)
+			assert.NoError(t, err)
+			assert.Equal(t, Number(test.Out), out)
+		})
+	}
+
+	for _, test := range errorTests {
 		t.Run(test.Name, func(t *testing.T) {
-			out := Eval(test.In)
+			_, err := Eval(test.In, nil)
+			var evalErr *EvalError
+			assert.ErrorAs(t, err, &evalErr)
+		})
+	}
+}
+
+func TestEvalEnv(t *testing.T) {
+	env := NewEnv()
+	env.Set("x", Number(3))
+
+	//2*x+1
+	nodes := []Node{
+		&BinaryNode{
+			Token: lexer.Token{Type: lexer.TOKEN_PLUS, Raw: "+"},
+			Left: &BinaryNode{
+				Token: lexer.Token{Type: lexer.TOKEN_ASTERISK, Raw: "*"},
+				Left:  &NumberNode{Token: lexer.Token{Type: lexer.TOKEN_NUMBER, Raw: "2"}},
+				Right: &VariableNode{Token: lexer.Token{Type: lexer.TOKEN_IDENT, Raw: "x"}},
+			},
+			Right: &NumberNode{Token: lexer.Token{Type: lexer.TOKEN_NUMBER, Raw: "1"}},
+		},
+	}
+
+	out, err := Eval(nodes, env)
+	assert.NoError(t, err)
+	assert.Equal(t, Number(7), out)
+
+	_, err = Eval(nodes, NewEnv())
+	var evalErr *EvalError
+	if assert.ErrorAs(t, err, &evalErr) {
+		assert.Equal(t, "undefined variable 'x'", evalErr.Message)
+	}
+}
+
+func TestEvalAssignment(t *testing.T) {
+	env := NewEnv()
+
+	//rate = 0.5; 10 * rate
+	nodes := []Node{
+		&AssignNode{
+			Token: lexer.Token{Type: lexer.TOKEN_ASSIGN, Raw: "="},
+			Name:  lexer.Token{Type: lexer.TOKEN_IDENT, Raw: "rate"},
+			Value: &NumberNode{Token: lexer.Token{Type: lexer.TOKEN_NUMBER, Raw: "0.5"}},
+		},
+		&BinaryNode{
+			Token: lexer.Token{Type: lexer.TOKEN_ASTERISK, Raw: "*"},
+			Left:  &NumberNode{Token: lexer.Token{Type: lexer.TOKEN_NUMBER, Raw: "10"}},
+			Right: &VariableNode{Token: lexer.Token{Type: lexer.TOKEN_IDENT, Raw: "rate"}},
+		},
+	}
+
+	out, err := Eval(nodes, env)
+	assert.NoError(t, err)
+	assert.Equal(t, Number(5), out)
+
+	rate, ok := env.Get("rate")
+	assert.True(t, ok)
+	assert.Equal(t, Number(0.5), rate)
+}
+
+func TestEvalCalls(t *testing.T) {
+	nonErrorTests := []struct {
+		In  string
+		Out float64
+	}{
+		{In: "sqrt(16) + abs(-2)", Out: 6},
+		{In: "max(1, 7, 3) - min(4, -1)", Out: 8},
+		{In: "log(8, 2)", Out: 3},
+		{In: "atan2(1, 1) * 4", Out: math.Pi},
+		{In: "round(2.5) + floor(-1.5) + ceil(1.2) + trunc(-1.7)", Out: 2},
+		{In: "hypot(3, 4)", Out: 5},
+		{In: "cos(pi) + ln(e) + tau / pi", Out: 2},
+		{In: "phi^2 - phi", Out: 1},
+		{In: "2e1 * e", Out: 20 * math.E},
+	}
+
+	errorTests := []struct {
+		In      string
+		Message string
+	}{
+		{In: "sin(1, 2)", Message: "sin expects 1 argument, got 2"},
+		{In: "log(8)", Message: "log expects 2 arguments, got 1"},
+		{In: "max()", Message: "max expects at least 1 argument, got 0"},
+		{In: "foo(1)", Message: "undefined function 'foo'"},
+		{In: "pi = 3", Message: "can't assign to constant 'pi'"},
+	}
+
+	for _, test := range nonErrorTests {
+		t.Run(test.In, func(t *testing.T) {
+			out, err := Eval(parse(t, test.In), nil)
+			assert.NoError(t, err)
+			assert.InDelta(t, test.Out, float64(out.(Number)), 1e-12)
+		})
+	}
+
+	for _, test := range errorTests {
+		t.Run(test.In, func(t *testing.T) {
+			_, err := Eval(parse(t, test.In), nil)
+			var evalErr *EvalError
+			if assert.ErrorAs(t, err, &evalErr) {
+				assert.Equal(t, test.Message, evalErr.Message)
+			}
+		})
+	}
+}
+
+func parse(t *testing.T, input string) []Node {
+	tokens, err := lexer.NewLexer(strings.NewReader(input)).Lex()
+	assert.NoError(t, err)
+	nodes, err := NewParser(tokens).Parse()
+	assert.NoError(t, err)
+	return nodes
+}
+
+func TestEvalSpecialConstants(t *testing.T) {
+	out, err := Eval(parse(t, "-inf"), nil)
+	assert.NoError(t, err)
+	assert.True(t, math.IsInf(float64(out.(Number)), -1))
+
+	out, err = Eval(parse(t, "nan + 1"), nil)
+	assert.NoError(t, err)
+	assert.True(t, math.IsNaN(float64(out.(Number))))
+}
+
+func TestEvalBaseLiterals(t *testing.T) {
+	tests := []struct {
+		In  string
+		Out float64
+	}{
+		{In: "0xFF + 0b1010_0101 + 0o755", Out: 255 + 165 + 493},
+		{In: "-0XdeadBEEF", Out: -3735928559},
+		{In: "0x20_0000_0000_0001", Out: 1 << 53},
+	}
+
+	for _, test := range tests {
+		t.Run(test.In, func(t *testing.T) {
+			out, err := Eval(parse(t, test.In), NewEnv())
+			assert.NoError(t, err)
+			assert.Equal(t, Number(test.Out), out)
+		})
+	}
+}
+
+func TestEvalBitwise(t *testing.T) {
+	nonErrorTests := []struct {
+		In  string
+		Out Value
+	}{
+		{In: "0xF0 | 0x0F", Out: Number(255)},
+		{In: "0b1100 & 0b1010", Out: Number(8)},
+		{In: "0b1100 xor 0b1010", Out: Number(6)},
+		{In: "~0", Out: Number(-1)},
+		{In: "-1 & 0xFF", Out: Number(255)},
+		{In: "1 << 10", Out: Number(1024)},
+		{In: "-8 >> 1", Out: Number(-4)},
+		{In: "5 >> 100", Out: Number(0)},
+		{In: "1 | 2 xor 3 & 4 << 1", Out: Number(3)},
+		{In: "1 << 2 + 1", Out: Number(8)},
+		{In: "6 & 3 == 2", Out: Bool(true)},
+		{In: "4.0 | 1e1", Out: Number(14)},
+	}
+
+	errorTests := []struct {
+		In      string
+		Message string
+	}{
+		{In: "2.5 & 1", Message: "'&' needs integers, 2.5 isn't an integer"},
+		{In: "~0.5", Message: "'~' needs integers, 0.5 isn't an integer"},
+		{In: "inf | 1", Message: "'|' needs integers, +Inf isn't an integer"},
+		{In: "1 << -1", Message: "negative shift count -1"},
+		{In: "1 << 1e9", Message: "shift count 1000000000 is too large"},
+		{In: "(1 < 2) & (2 < 3)", Message: "can't apply '&' to bool and bool"},
+	}
+
+	for _, test := range nonErrorTests {
+		t.Run(test.In, func(t *testing.T) {
+			out, err := Eval(parse(t, test.In), nil)
+			assert.NoError(t, err)
+			assert.Equal(t, test.Out, out)
+		})
+	}
+
+	for _, test := range errorTests {
+		t.Run(test.In, func(t *testing.T) {
+			_, err := Eval(parse(t, test.In), nil)
+			var evalErr *EvalError
+			if assert.ErrorAs(t, err, &evalErr) {
+				assert.Equal(t, test.Message, evalErr.Message)
+			}
+		})
+	}
+}
+
+func TestEvalPrecedence(t *testing.T) {
+	tests := []struct {
+		In     string
+		Out    float64
+		Legacy float64
+	}{
+		{In: "2^3^2", Out: 512, Legacy: 64},
+		{In: "-2^2", Out: -4, Legacy: 4},
+		{In: "2^-1", Out: 0.5, Legacy: 0.5},
+		{In: "2^-1^2", Out: 0.5, Legacy: 0.25},
+		{In: "-2*-3", Out: 6, Legacy: 6},
+		{In: "2*3^2", Out: 18, Legacy: 18},
+	}
+
+	for _, test := range tests {
+		t.Run(test.In, func(t *testing.T) {
+			tokens, err := lexer.NewLexer(strings.NewReader(test.In)).Lex()
+			assert.NoError(t, err)
+
+			nodes, err := NewParser(tokens).Parse()
+			assert.NoError(t, err)
+			out, err := Eval(nodes, nil)
+			assert.NoError(t, err)
+			assert.Equal(t, Number(test.Out), out)
+
+			legacy := NewParser(tokens)
+			legacy.Mode = LegacyPrecedence
+			nodes, err = legacy.Parse()
+			assert.NoError(t, err)
+			out, err = Eval(nodes, nil)
+			assert.NoError(t, err)
+			assert.Equal(t, Number(test.Legacy), out)
+		})
+	}
+}
+
+func TestEvalComparisons(t *testing.T) {
+	nonErrorTests := []struct {
+		In  string
+		Out Value
+	}{
+		{In: "income = 60000; income > 50000", Out: Bool(true)},
+		{In: "1 + 2 == 3", Out: Bool(true)},
+		{In: "0.1 + 0.2 != 0.3", Out: Bool(true)},
+		{In: "2 < 2", Out: Bool(false)},
+		{In: "2 <= 2", Out: Bool(true)},
+		{In: "-1 >= 1 - 2 * 1", Out: Bool(true)},
+		{In: "(1 < 2) == (3 > 2)", Out: Bool(true)},
+		{In: "(1 < 2) != (3 < 2)", Out: Bool(true)},
+		{In: "nan == nan", Out: Bool(false)},
+	}
+
+	errorTests := []struct {
+		In      string
+		Message string
+	}{
+		{In: "1 < 2 < 3", Message: "can't apply '<' to bool and number"},
+		{In: "(1 < 2) + 1", Message: "can't apply '+' to bool and number"},
+		{In: "-(1 < 2)", Message: "can't apply '-' to bool"},
+		{In: "(1 < 2) < (2 < 3)", Message: "can't apply '<' to bool and bool"},
+		{In: "sqrt(1 < 2)", Message: "sqrt expects numbers, argument 1 is bool"},
+	}
+
+	for _, test := range nonErrorTests {
+		t.Run(test.In, func(t *testing.T) {
+			out, err := Eval(parse(t, test.In), nil)
+			assert.NoError(t, err)
 			assert.Equal(t, test.Out, out)
 		})
 	}
+
+	for _, test := range errorTests {
+		t.Run(test.In, func(t *testing.T) {
+			_, err := Eval(parse(t, test.In), nil)
+			var evalErr *EvalError
+			if assert.ErrorAs(t, err, &evalErr) {
+				assert.Equal(t, test.Message, evalErr.Message)
+			}
+		})
+	}
+}
+
+func TestEvalLogical(t *testing.T) {
+	nonErrorTests := []struct {
+		In  string
+		Out Value
+	}{
+		{In: "x = 0; x != 0 && 10/x > 2", Out: Bool(false)},
+		{In: "x = 4; x != 0 && 10/x > 2", Out: Bool(true)},
+		{In: "1 > 2 && undefined", Out: Bool(false)},
+		{In: "1 < 2 || undefined", Out: Bool(true)},
+		{In: "1 < 2 and not 2 < 1", Out: Bool(true)},
+		{In: "1 > 2 or 2 > 3 or 3 > 2", Out: Bool(true)},
+		{In: "!(1 < 2)", Out: Bool(false)},
+		{In: "!1 > 2", Out: Bool(true)},
+		{In: "1 < 2 || 1 < 2 && 1 > 2", Out: Bool(true)},
+		{In: "!!(1 == 1)", Out: Bool(true)},
+	}
+
+	errorTests := []struct {
+		In      string
+		Message string
+	}{
+		{In: "1 && 1 < 2", Message: "can't apply '&&' to number"},
+		{In: "1 > 2 or 5", Message: "can't apply 'or' to number"},
+		{In: "!5", Message: "can't apply '!' to number"},
+		{In: "-(1 < 2)", Message: "can't apply '-' to bool"},
+	}
+
+	for _, test := range nonErrorTests {
+		t.Run(test.In, func(t *testing.T) {
+			out, err := Eval(parse(t, test.In), nil)
+			assert.NoError(t, err)
+			assert.Equal(t, test.Out, out)
+		})
+	}
+
+	for _, test := range errorTests {
+		t.Run(test.In, func(t *testing.T) {
+			_, err := Eval(parse(t, test.In), nil)
+			var evalErr *EvalError
+			if assert.ErrorAs(t, err, &evalErr) {
+				assert.Equal(t, test.Message, evalErr.Message)
+			}
+		})
+	}
+}
+
+func TestEvalConditional(t *testing.T) {
+	nonErrorTests := []struct {
+		In  string
+		Out Value
+	}{
+		{In: "x = 5; x > 3 ? 10 : 20", Out: Number(10)},
+		{In: "x = 1; x > 3 ? 10 : 20", Out: Number(20)},
+		{In: "1 < 2 ? 1 : undefined", Out: Number(1)},
+		{In: "1 > 2 ? undefined : 2", Out: Number(2)},
+		{In: "q = 150; q < 100 ? 10 : q < 200 ? 9 : 8", Out: Number(9)},
+		{In: "1 < 2 ? 3 > 4 ? 1 : 2 : 3", Out: Number(2)},
+		{In: "1 + (1 < 2 ? 1 : 0) * 2", Out: Number(3)},
+		{In: "if(2 > 1, 1, undefined)", Out: Number(1)},
+		{In: "q = 250; 100 * if(q < 100, 1, if(q < 200, 0.9, 0.8))", Out: Number(80)},
+		{In: "1 < 2 || 1 > 2 ? 1 < 2 : 1 > 2", Out: Bool(true)},
+	}
+
+	errorTests := []struct {
+		In      string
+		Message string
+	}{
+		{In: "1 ? 2 : 3", Message: "condition must be bool, got number"},
+		{In: "if(0, 2, 3)", Message: "condition must be bool, got number"},
+	}
+
+	for _, test := range nonErrorTests {
+		t.Run(test.In, func(t *testing.T) {
+			out, err := Eval(parse(t, test.In), nil)
+			assert.NoError(t, err)
+			assert.Equal(t, test.Out, out)
+		})
+	}
+
+	for _, test := range errorTests {
+		t.Run(test.In, func(t *testing.T) {
+			_, err := Eval(parse(t, test.In), nil)
+			var evalErr *EvalError
+			if assert.ErrorAs(t, err, &evalErr) {
+				assert.Equal(t, test.Message, evalErr.Message)
+			}
+		})
+	}
 }
@@ -0,0 +1,324 @@
+package parser
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"strings"
+)
+
+// Notation is how Format prints numbers.
+type Notation int
+
+const (
+	// Shortest prints the shortest text that reads back as the same value,
+	// like String of the value does. Exact numbers keep their exact form,
+	// 1/3 of rational mode is printed as a fraction.
+	Shortest Notation = iota
+	// Fixed prints digits after the point, like 1234.50.
+	Fixed
+	// Scientific prints one digit before the point and an exponent, like
+	// 1.2345e+03.
+	Scientific
+	// Engineering is Scientific with the exponent a multiple of 3 and one to
+	// three digits before the point, like 1.2345e+03 or 12.345e+06.
+	Engineering
+	// Hex, Binary and Octal print integers like literals of their base, 0xFF,
+	// 0b101 and 0o755. Numbers that aren't integers are errors.
+	Hex
+	Binary
+	Octal
+	// Fraction prints numbers like 1/3 or 2. Float numbers are printed as a
+	// short fraction that rounds to the same number, 0.1 of float64 is 1/10.
+	Fraction
+)
+
+var notationNames = map[Notation]string{
+	Shortest:    "shortest",
+	Fixed:       "decimal",
+	Scientific:  "scientific",
+	Engineering: "engineering",
+	Hex:         "hex",
+	Binary:      "bin",
+	Octal:       "oct",
+	Fraction:    "fraction",
+}
+
+func (n Notation) String() string {
+	if name, ok := notationNames[n]; ok {
+		return name
+	}
+	return fmt.Sprintf("Notation(%d)", int(n))
+}
+
+// ParseNotation returns the notation called name: "shortest", "decimal",
+// "scientific", "engineering", "hex", "bin", "oct" or "fraction".
+func ParseNotation(name string) (Notation, error) {
+	for notation, n := range notationNames {
+		if n == name {
+			return notation, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown format '%s'", name)
+}
+
+// MaxPrecision is the largest Precision of FormatOptions, more digits would
+// only let a single expression take all memory and time.
+const MaxPrecision = 1000
+
+// FormatOptions configure Format.
+type FormatOptions struct {
+	Notation Notation
+	// Precision is the number of digits after the point of Fixed, Scientific
+	// and Engineering. -1 prints as many as the value needs: the shortest
+	// round-trip digits of float numbers and all digits of exact ones, which
+	// is an error for fractions like 1/3 without a finite decimal form.
+	Precision int
+}
+
+// Format prints v in the notation of opts. Digits are rounded half to even
+// from the exact value of v, so 2.675 of float64, which is slightly less
+// than that, is 2.67 at precision 2. Booleans, infinities and NaN are printed
+// the same in every notation.
+func Format(v Value, opts FormatOptions) (string, error) {
+	if opts.Notation == Shortest {
+		return v.String(), nil
+	}
+	if opts.Precision > MaxPrecision {
+		return "", fmt.Errorf("precision %d is larger than %d", opts.Precision, MaxPrecision)
+	}
+
+	switch v := v.(type) {
+	case Bool:
+		return v.String(), nil
+	case Quantity:
+		s, err := Format(v.value, opts)
+		if err != nil {
+			return "", err
+		}
+		return s + " " + v.unit.String(), nil
+	case Complex:
+		return formatComplex(v, opts)
+	}
+
+	r, err := exactValue(v, opts.Precision < 0)
+	if err != nil {
+		return "", err
+	}
+	if r == nil {
+		return v.String(), nil
+	}
+
+	switch opts.Notation {
+	case Fixed:
+		return formatFixed(r, opts.Precision)
+	case Scientific:
+		return formatScientific(r, opts.Precision, 1)
+	case Engineering:
+		return formatScientific(r, opts.Precision, 3)
+	case Hex:
+		return formatInteger(v, r, 16, "0x")
+	case Binary:
+		return formatInteger(v, r, 2, "0b")
+	case Octal:
+		return formatInteger(v, r, 8, "0o")
+	case Fraction:
+		return formatFraction(v, r), nil
+	}
+	return "", fmt.Errorf("unknown notation %s", opts.Notation)
+}
+
+// exactValue returns the value of v as a fraction, or nil for infinities and
+// NaN. With shortest float numbers are taken by their shortest decimal form
+// instead of their exact binary value.
+func exactValue(v Value, shortest bool) (*big.Rat, error) {
+	switch v := v.(type) {
+	case Number:
+		f := float64(v)
+		if math.IsInf(f, 0) || math.IsNaN(f) {
+			return nil, nil
+		}
+		if shortest {
+			return ratFromFloat(f)
+		}
+		return new(big.Rat).SetFloat64(f), nil
+	case BigFloat:
+		if v.f.IsInf() {
+			return nil, nil
+		}
+		if shortest {
+			return ratLiteral(v.String())
+		}
+		r, _ := v.f.Rat(nil)
+		return r, nil
+	case Rational:
+		return v.Big(), nil
+	case Decimal:
+		return v.rat(), nil
+	case Integer:
+		return new(big.Rat).SetInt64(int64(v)), nil
+	}
+	return nil, fmt.Errorf("can't format %T", v)
+}
+
+func formatFixed(r *big.Rat, precision int) (string, error) {
+	places, err := decimalPlaces(r, precision)
+	if err != nil {
+		return "", err
+	}
+	return DecimalArithmetic{Scale: places}.round(r).String(), nil
+}
+
+// formatScientific prints r with an exponent that is a multiple of step.
+func formatScientific(r *big.Rat, precision int, step int) (string, error) {
+	if _, err := decimalPlaces(r, precision); err != nil {
+		return "", err
+	}
+	if r.Sign() == 0 {
+		mantissa, err := formatFixed(r, precision)
+		return mantissa + "e+00", err
+	}
+
+	exp := decimalExponent(r)
+	exp -= ((exp % step) + step) % step
+
+	//rounding may carry the mantissa up to 10^step, like 9.996 to 10.00, the exponent grows then
+	limit := new(big.Rat).SetInt(pow10(uint(step)))
+	for {
+		mantissa := new(big.Rat).Mul(r, ratPow10(-exp))
+		places, _ := decimalPlaces(mantissa, precision)
+		d := DecimalArithmetic{Scale: places}.round(mantissa)
+		if new(big.Rat).Abs(d.rat()).Cmp(limit) < 0 {
+			return fmt.Sprintf("%se%+03d", d, exp), nil
+		}
+		exp += step
+	}
+}
+
+func formatInteger(v Value, r *big.Rat, base int, prefix string) (string, error) {
+	if !r.IsInt() {
+		return "", fmt.Errorf("%s isn't an integer", v)
+	}
+
+	n := r.Num()
+	s := prefix + strings.ToUpper(new(big.Int).Abs(n).Text(base))
+	if n.Sign() < 0 {
+		s = "-" + s
+	}
+	return s, nil
+}
+
+// formatFraction prints r, the exact value of v, as a fraction. Float
+// numbers get the first convergent of the continued fraction of r that rounds
+// to the same number, exact numbers are printed as they are.
+func formatFraction(v Value, r *big.Rat) string {
+	var same func(*big.Rat) bool
+	switch v := v.(type) {
+	case Number:
+		same = func(c *big.Rat) bool {
+			f, _ := c.Float64()
+			return f == float64(v)
+		}
+	case BigFloat:
+		same = func(c *big.Rat) bool {
+			return new(big.Float).SetPrec(v.f.Prec()).SetRat(c).Cmp(v.f) == 0
+		}
+	default:
+		return r.RatString()
+	}
+
+	//h/k are the convergents, h1/k1 and h0/k0 the two before them
+	h0, h1 := big.NewInt(0), big.NewInt(1)
+	k0, k1 := big.NewInt(1), big.NewInt(0)
+	rest := new(big.Rat).Set(r)
+	for {
+		a := new(big.Int).Div(rest.Num(), rest.Denom())
+		h := new(big.Int).Add(new(big.Int).Mul(a, h1), h0)
+		k := new(big.Int).Add(new(big.Int).Mul(a, k1), k0)
+		convergent := new(big.Rat).SetFrac(h, k)
+
+		rest.Sub(rest, new(big.Rat).SetInt(a))
+		if rest.Sign() == 0 || same(convergent) {
+			return convergent.RatString()
+		}
+		rest.Inv(rest)
+		h0, h1, k0, k1 = h1, h, k1, k
+	}
+}
+
+// formatComplex formats both parts of c, like Complex.String does.
+func formatComplex(c Complex, opts FormatOptions) (string, error) {
+	//1/2+1/3i would read back as 1/2 + 1/(3i)
+	if opts.Notation == Fraction && imag(c) != 0 {
+		return "", fmt.Errorf("%s has no fraction form", c)
+	}
+
+	re, err := Format(Number(real(c)), opts)
+	if err != nil {
+		return "", err
+	}
+	im, err := Format(Number(math.Abs(imag(c))), opts)
+	if err != nil {
+		return "", err
+	}
+
+	sign := "+"
+	if math.Signbit(imag(c)) {
+		sign = "-"
+	}
+	switch {
+	case imag(c) == 0:
+		return re, nil
+	case real(c) == 0:
+		return strings.TrimPrefix(sign, "+") + im + "i", nil
+	}
+	return re + sign + im + "i", nil
+}
+
+// decimalPlaces returns precision, or for -1 the number of digits after the
+// point of r written in full.
+func decimalPlaces(r *big.Rat, precision int) (uint, error) {
+	if precision >= 0 {
+		return uint(precision), nil
+	}
+
+	//r has a finite decimal form only when its denominator is 2^a * 5^b, it has max(a, b) digits then
+	den := new(big.Int).Set(r.Denom())
+	twos := den.TrailingZeroBits()
+	den.Rsh(den, twos)
+	fives := uint(0)
+	five, m := big.NewInt(5), new(big.Int)
+	for {
+		q, rem := new(big.Int).QuoRem(den, five, m)
+		if rem.Sign() != 0 {
+			break
+		}
+		den = q
+		fives++
+	}
+	if den.Cmp(big.NewInt(1)) != 0 {
+		return 0, fmt.Errorf("%s has no finite decimal form, set a precision", r.RatString())
+	}
+	return max(twos, fives), nil
+}
+
+// decimalExponent returns the exponent of the leading digit of r, which
+// isn't 0: 2 for 123 and -2 for 0.0123.
+func decimalExponent(r *big.Rat) int {
+	abs := new(big.Rat).Abs(r)
+	exp := len(abs.Num().String()) - len(abs.Denom().String())
+	for abs.Cmp(ratPow10(exp)) < 0 {
+		exp--
+	}
+	for abs.Cmp(ratPow10(exp+1)) >= 0 {
+		exp++
+	}
+	return exp
+}
+
+func ratPow10(exp int) *big.Rat {
+	if exp < 0 {
+		return new(big.Rat).SetFrac(big.NewInt(1), pow10(uint(-exp)))
+	}
+	return new(big.Rat).SetInt(pow10(uint(exp)))
+}
@@ -0,0 +1,102 @@
+package parser
+
+import (
+	"math"
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFormat(t *testing.T) {
+	nonErrorTests := []struct {
+		Name string
+		In   Value
+		Opts FormatOptions
+		Out  string
+	}{
+		{Name: "shortest float", In: Number(1e21 + 1e5), Opts: FormatOptions{Notation: Shortest}, Out: "1.0000000000000001e+21"},
+		{Name: "shortest rational", In: Rational{big.NewRat(1, 3)}, Opts: FormatOptions{Notation: Shortest}, Out: "1/3"},
+		{Name: "fixed", In: Number(1234.5), Opts: FormatOptions{Notation: Fixed, Precision: 2}, Out: "1234.50"},
+		{Name: "fixed rounds the exact value", In: Number(2.675), Opts: FormatOptions{Notation: Fixed, Precision: 2}, Out: "2.67"},
+		{Name: "fixed rounds half to even", In: Rational{big.NewRat(5, 2)}, Opts: FormatOptions{Notation: Fixed, Precision: 0}, Out: "2"},
+		{Name: "fixed with all digits", In: Number(1e21), Opts: FormatOptions{Notation: Fixed, Precision: -1}, Out: "1000000000000000000000"},
+		{Name: "fixed rational", In: Rational{big.NewRat(2, 3)}, Opts: FormatOptions{Notation: Fixed, Precision: 3}, Out: "0.667"},
+		{Name: "scientific", In: Number(1234.5678), Opts: FormatOptions{Notation: Scientific, Precision: -1}, Out: "1.2345678e+03"},
+		{Name: "scientific small", In: Number(-0.000123456), Opts: FormatOptions{Notation: Scientific, Precision: 2}, Out: "-1.23e-04"},
+		{Name: "scientific carry", In: Number(9.996), Opts: FormatOptions{Notation: Scientific, Precision: 2}, Out: "1.00e+01"},
+		{Name: "scientific zero", In: Integer(0), Opts: FormatOptions{Notation: Scientific, Precision: 1}, Out: "0.0e+00"},
+		{Name: "scientific big float", In: mustBigFloat("2e100"), Opts: FormatOptions{Notation: Scientific, Precision: 3}, Out: "2.000e+100"},
+		{Name: "engineering", In: Number(-0.000123456), Opts: FormatOptions{Notation: Engineering, Precision: 2}, Out: "-123.46e-06"},
+		{Name: "engineering carry", In: Number(999999), Opts: FormatOptions{Notation: Engineering, Precision: 1}, Out: "1.0e+06"},
+		{Name: "engineering decimal", In: Decimal{big.NewInt(1234500), 2}, Opts: FormatOptions{Notation: Engineering, Precision: -1}, Out: "12.345e+03"},
+		{Name: "hex", In: Number(255), Opts: FormatOptions{Notation: Hex}, Out: "0xFF"},
+		{Name: "negative hex", In: Integer(-255), Opts: FormatOptions{Notation: Hex}, Out: "-0xFF"},
+		{Name: "binary", In: Rational{big.NewRat(10, 2)}, Opts: FormatOptions{Notation: Binary}, Out: "0b101"},
+		{Name: "octal", In: Decimal{big.NewInt(49300), 2}, Opts: FormatOptions{Notation: Octal}, Out: "0o755"},
+		{Name: "complex", In: Complex(1000 - 2000i), Opts: FormatOptions{Notation: Scientific, Precision: 1}, Out: "1.0e+03-2.0e+03i"},
+		{Name: "imaginary hex", In: Complex(-16i), Opts: FormatOptions{Notation: Hex}, Out: "-0x10i"},
+		{Name: "infinity", In: Number(math.Inf(1)), Opts: FormatOptions{Notation: Hex}, Out: "+Inf"},
+		{Name: "bool", In: Bool(true), Opts: FormatOptions{Notation: Scientific}, Out: "true"},
+		{Name: "fraction of float", In: Number(1.0 / 3), Opts: FormatOptions{Notation: Fraction}, Out: "1/3"},
+		{Name: "fraction of big float", In: mustBigFloat("0.1"), Opts: FormatOptions{Notation: Fraction}, Out: "1/10"},
+		{Name: "negative fraction", In: Number(-2.75), Opts: FormatOptions{Notation: Fraction}, Out: "-11/4"},
+		{Name: "fraction of decimal", In: Decimal{big.NewInt(125), 2}, Opts: FormatOptions{Notation: Fraction}, Out: "5/4"},
+		{Name: "fraction of integer", In: Integer(7), Opts: FormatOptions{Notation: Fraction}, Out: "7"},
+	}
+
+	errorTests := []struct {
+		Name    string
+		In      Value
+		Opts    FormatOptions
+		Message string
+	}{
+		{Name: "hex of fraction", In: Number(2.5), Opts: FormatOptions{Notation: Hex}, Message: "2.5 isn't an integer"},
+		{Name: "fraction of complex", In: Complex(1 + 1i), Opts: FormatOptions{Notation: Fraction}, Message: "1+1i has no fraction form"},
+		{Name: "too many digits", In: Rational{big.NewRat(1, 3)}, Opts: FormatOptions{Notation: Fixed, Precision: 100000000}, Message: "precision 100000000 is larger than 1000"},
+		{Name: "all digits of 1/3", In: Rational{big.NewRat(1, 3)}, Opts: FormatOptions{Notation: Scientific, Precision: -1}, Message: "1/3 has no finite decimal form, set a precision"},
+	}
+
+	for _, test := range nonErrorTests {
+		t.Run(test.Name, func(t *testing.T) {
+			out, err := Format(test.In, test.Opts)
+			assert.NoError(t, err)
+			assert.Equal(t, test.Out, out)
+		})
+	}
+
+	for _, test := range errorTests {
+		t.Run(test.Name, func(t *testing.T) {
+			_, err := Format(test.In, test.Opts)
+			assert.EqualError(t, err, test.Message)
+		})
+	}
+}
+
+func TestFormatExactFraction(t *testing.T) {
+	//an exact number is its own fraction, its continued fraction of thousands of terms isn't expanded
+	r := new(big.Rat).SetFrac(new(big.Int).Exp(big.NewInt(355), big.NewInt(3000), nil), new(big.Int).Exp(big.NewInt(113), big.NewInt(3000), nil))
+	out, err := Format(Rational{r}, FormatOptions{Notation: Fraction})
+	if assert.NoError(t, err) {
+		assert.Equal(t, r.RatString(), out)
+	}
+}
+
+func TestParseNotation(t *testing.T) {
+	for notation, name := range notationNames {
+		parsed, err := ParseNotation(name)
+		assert.NoError(t, err)
+		assert.Equal(t, notation, parsed)
+	}
+
+	_, err := ParseNotation("roman")
+	assert.EqualError(t, err, "unknown format 'roman'")
+}
+
+func mustBigFloat(s string) BigFloat {
+	f, _, err := big.ParseFloat(s, 10, DefaultPrec, big.ToNearestEven)
+	if err != nil {
+		panic(err)
+	}
+	return BigFloat{f}
+}
@@ -0,0 +1,107 @@
+package parser
+
+import (
+	"maps"
+
+	"github.com/Yarik7610/expressive/lexer"
+)
+
+// Precedences, from the loosest to the tightest binding.
+const (
+	precLowest      = iota
+	precDirective   // x in hex, x as fraction, x in m/s, x to km
+	precConditional // c ? a : b
+	precOr          // || or
+	precAnd         // && and
+	precNot         // !x not x
+	precComparison  // == != < <= > >=
+	precBitOr       // |
+	precBitXor      // xor
+	precBitAnd      // &
+	precShift       // << >>
+	precTerm        // + -
+	precFactor      // * / %
+	precUnary       // -x ~x
+	precPower       // ^
+
+	//with LegacyPrecedence unary minus binds tighter than "^"
+	precLegacyUnary
+)
+
+type prefixRule struct {
+	precedence int
+	parse      func(p *Parser, token lexer.Token) (Node, error)
+}
+
+type infixRule struct {
+	precedence int
+	rightAssoc bool
+	parse      func(p *Parser, op lexer.Token, left Node) (Node, error)
+}
+
+type postfixRule struct {
+	precedence int
+	parse      func(p *Parser, op lexer.Token, left Node) (Node, error)
+}
+
+// grammar maps tokens to the rules parsing them. A prefix rule parses a token
+// that starts an expression, an infix rule parses an operator with operands on
+// both sides and a postfix rule parses an operator following its operand.
+//
+// To add an operator, add its token to the lexer and a rule here.
+type grammar struct {
+	prefix  map[int]prefixRule
+	infix   map[int]infixRule
+	postfix map[int]postfixRule
+}
+
+var standardGrammar = grammar{
+	prefix: map[int]prefixRule{
+		lexer.TOKEN_NUMBER:     {precLowest, (*Parser).parseNumber},
+		lexer.TOKEN_IMAGINARY:  {precLowest, (*Parser).parseNumber},
+		lexer.TOKEN_IDENT:      {precLowest, (*Parser).parseIdent},
+		lexer.TOKEN_IF:         {precLowest, (*Parser).parseIf},
+		lexer.TOKEN_BRACE_LEFT: {precLowest, (*Parser).parseGroup},
+		lexer.TOKEN_MINUS:      {precUnary, (*Parser).parseUnary},
+		lexer.TOKEN_NOT:        {precNot, (*Parser).parseUnary},
+		lexer.TOKEN_TILDE:      {precUnary, (*Parser).parseUnary},
+	},
+	infix: map[int]infixRule{
+		lexer.TOKEN_QUESTION:      {precConditional, true, (*Parser).parseConditional},
+		lexer.TOKEN_OR:            {precOr, false, (*Parser).parseLogical},
+		lexer.TOKEN_AND:           {precAnd, false, (*Parser).parseLogical},
+		lexer.TOKEN_EQUAL:         {precComparison, false, (*Parser).parseBinary},
+		lexer.TOKEN_NOT_EQUAL:     {precComparison, false, (*Parser).parseBinary},
+		lexer.TOKEN_LESS:          {precComparison, false, (*Parser).parseBinary},
+		lexer.TOKEN_LESS_EQUAL:    {precComparison, false, (*Parser).parseBinary},
+		lexer.TOKEN_GREATER:       {precComparison, false, (*Parser).parseBinary},
+		lexer.TOKEN_GREATER_EQUAL: {precComparison, false, (*Parser).parseBinary},
+		lexer.TOKEN_PIPE:          {precBitOr, false, (*Parser).parseBinary},
+		lexer.TOKEN_XOR:           {precBitXor, false, (*Parser).parseBinary},
+		lexer.TOKEN_AMPERSAND:     {precBitAnd, false, (*Parser).parseBinary},
+		lexer.TOKEN_SHIFT_LEFT:    {precShift, false, (*Parser).parseBinary},
+		lexer.TOKEN_SHIFT_RIGHT:   {precShift, false, (*Parser).parseBinary},
+		lexer.TOKEN_PLUS:          {precTerm, false, (*Parser).parseBinary},
+		lexer.TOKEN_MINUS:         {precTerm, false, (*Parser).parseBinary},
+		lexer.TOKEN_ASTERISK:      {precFactor, false, (*Parser).parseBinary},
+		lexer.TOKEN_SLASH:         {precFactor, false, (*Parser).parseBinary},
+		lexer.TOKEN_PERCENT:       {precFactor, false, (*Parser).parseBinary},
+		lexer.TOKEN_CARET:         {precPower, true, (*Parser).parseBinary},
+	},
+	postfix: map[int]postfixRule{
+		lexer.TOKEN_IN: {precDirective, (*Parser).parseDirective},
+		lexer.TOKEN_AS: {precDirective, (*Parser).parseDirective},
+		lexer.TOKEN_TO: {precDirective, (*Parser).parseConversion},
+	},
+}
+
+var legacyGrammar = func() grammar {
+	g := grammar{
+		prefix:  maps.Clone(standardGrammar.prefix),
+		infix:   maps.Clone(standardGrammar.infix),
+		postfix: maps.Clone(standardGrammar.postfix),
+	}
+	g.prefix[lexer.TOKEN_MINUS] = prefixRule{precLegacyUnary, (*Parser).parseUnary}
+	g.infix[lexer.TOKEN_CARET] = infixRule{precPower, false, (*Parser).parseBinary}
+	return g
+}()
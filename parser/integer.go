@@ -0,0 +1,237 @@
+package parser
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"slices"
+	"strconv"
+
+	"github.com/Yarik7610/expressive/lexer"
+)
+
+// IntegerArithmetic evaluates with int64 numbers: "/" truncates towards zero,
+// so 7/2 is 3, and "%" is the remainder of that division. Results that don't
+// fit into int64 are errors rather than wrapping around.
+//
+// Fractional literals are rejected by the IntegerLiterals mode of Parser, the
+// arithmetic itself rejects them too. abs, min, max, re, im, conj and the
+// rounding functions work on int64, other functions are computed in float64 and their
+// results must be integers.
+type IntegerArithmetic struct{}
+
+// Integer is a number of IntegerArithmetic.
+type Integer int64
+
+func (i Integer) String() string {
+	return strconv.FormatInt(int64(i), 10)
+}
+
+func (i Integer) kind() string {
+	return "number"
+}
+
+func (IntegerArithmetic) Literal(raw string) (Value, error) {
+	r, err := ratLiteral(raw)
+	if err != nil {
+		return nil, err
+	}
+	if !r.IsInt() {
+		return nil, fmt.Errorf("%s isn't an integer", raw)
+	}
+	if !r.Num().IsInt64() {
+		return nil, fmt.Errorf("%s overflows int64", raw)
+	}
+	return Integer(r.Num().Int64()), nil
+}
+
+func (IntegerArithmetic) FromFloat(f float64) (Value, error) {
+	if f != math.Trunc(f) {
+		return nil, fmt.Errorf("%s isn't an integer", Number(f))
+	}
+	//float64(math.MaxInt64) rounds up to 2^63, which is already out of range
+	if f < math.MinInt64 || f >= math.MaxInt64 {
+		return nil, fmt.Errorf("%s overflows int64", Number(f))
+	}
+	return Integer(f), nil
+}
+
+func (a IntegerArithmetic) Float(v Value) (float64, error) {
+	i, err := a.operand(v)
+	if err != nil {
+		return 0, err
+	}
+	return float64(i), nil
+}
+
+func (a IntegerArithmetic) Binary(op lexer.Token, left Value, right Value) (Value, error) {
+	l, err := a.operand(left)
+	if err != nil {
+		return nil, err
+	}
+	r, err := a.operand(right)
+	if err != nil {
+		return nil, err
+	}
+
+	//results are computed on big.Int and checked to fit into int64
+	x, y, z := big.NewInt(l), big.NewInt(r), new(big.Int)
+	switch op.Type {
+	case lexer.TOKEN_PLUS:
+		z.Add(x, y)
+	case lexer.TOKEN_MINUS:
+		z.Sub(x, y)
+	case lexer.TOKEN_ASTERISK:
+		z.Mul(x, y)
+	case lexer.TOKEN_SLASH:
+		if r == 0 {
+			return nil, errors.New("division by zero")
+		}
+		z.Quo(x, y)
+	case lexer.TOKEN_PERCENT:
+		if r == 0 {
+			return nil, errors.New("modulo by zero")
+		}
+		z.Rem(x, y)
+	case lexer.TOKEN_CARET:
+		if err := intPow(z, l, r); err != nil {
+			return nil, err
+		}
+	case lexer.TOKEN_EQUAL:
+		return Bool(l == r), nil
+	case lexer.TOKEN_NOT_EQUAL:
+		return Bool(l != r), nil
+	case lexer.TOKEN_LESS:
+		return Bool(l < r), nil
+	case lexer.TOKEN_LESS_EQUAL:
+		return Bool(l <= r), nil
+	case lexer.TOKEN_GREATER:
+		return Bool(l > r), nil
+	case lexer.TOKEN_GREATER_EQUAL:
+		return Bool(l >= r), nil
+	default:
+		return nil, fmt.Errorf("undefined operator '%s'", op.Raw)
+	}
+
+	if !z.IsInt64() {
+		return nil, fmt.Errorf("%d %s %d overflows int64", l, op.Raw, r)
+	}
+	return Integer(z.Int64()), nil
+}
+
+func (a IntegerArithmetic) Negate(v Value) (Value, error) {
+	i, err := a.operand(v)
+	if err != nil {
+		return nil, err
+	}
+	if i == math.MinInt64 {
+		return nil, fmt.Errorf("-(%d) overflows int64", i)
+	}
+	return -Integer(i), nil
+}
+
+func (a IntegerArithmetic) native(name string) (func(args []Value) (Value, error), bool) {
+	fn, ok := integerBuiltins[name]
+	if !ok {
+		return nil, false
+	}
+
+	return func(args []Value) (Value, error) {
+		operands := make([]int64, 0, len(args))
+		for _, arg := range args {
+			i, err := a.operand(arg)
+			if err != nil {
+				return nil, err
+			}
+			operands = append(operands, i)
+		}
+
+		i, err := fn(operands...)
+		if err != nil {
+			return nil, err
+		}
+		return Integer(i), nil
+	}, true
+}
+
+func (a IntegerArithmetic) integer(v Value) (*big.Int, error) {
+	i, err := a.operand(v)
+	if err != nil {
+		return nil, err
+	}
+	return big.NewInt(i), nil
+}
+
+func (IntegerArithmetic) fromInteger(n *big.Int) (Value, error) {
+	if !n.IsInt64() {
+		return nil, fmt.Errorf("%s overflows int64", n)
+	}
+	return Integer(n.Int64()), nil
+}
+
+func (a IntegerArithmetic) operand(v Value) (int64, error) {
+	switch v := v.(type) {
+	case Integer:
+		return int64(v), nil
+	case Number:
+		i, err := a.FromFloat(float64(v))
+		if err != nil {
+			return 0, err
+		}
+		return int64(i.(Integer)), nil
+	}
+	return 0, fmt.Errorf("can't use %T in integer arithmetic", v)
+}
+
+func integerIdentity(args ...int64) (int64, error) {
+	return args[0], nil
+}
+
+var integerBuiltins = map[string]func(args ...int64) (int64, error){
+	"abs": func(args ...int64) (int64, error) {
+		switch {
+		case args[0] == math.MinInt64:
+			return 0, fmt.Errorf("abs(%d) overflows int64", args[0])
+		case args[0] < 0:
+			return -args[0], nil
+		}
+		return args[0], nil
+	},
+	"floor": integerIdentity,
+	"ceil":  integerIdentity,
+	"round": integerIdentity,
+	"trunc": integerIdentity,
+	"re":    integerIdentity,
+	"conj":  integerIdentity,
+	"im": func(args ...int64) (int64, error) {
+		return 0, nil
+	},
+	"min": func(args ...int64) (int64, error) {
+		return slices.Min(args), nil
+	},
+	"max": func(args ...int64) (int64, error) {
+		return slices.Max(args), nil
+	},
+}
+
+// intPow sets z to x^n. Negative exponents are errors unless x is 1 or -1,
+// there's no integer result for them.
+func intPow(z *big.Int, x int64, n int64) error {
+	switch {
+	case n >= 0:
+	case x == 1 || x == -1:
+		n = -n
+	case x == 0:
+		return errors.New("division by zero")
+	default:
+		return fmt.Errorf("%d ^ %d isn't an integer", x, n)
+	}
+
+	//2^64 already overflows, so larger exponents would only waste time
+	if (x < -1 || x > 1) && n > 64 {
+		n = 64
+	}
+	z.Exp(big.NewInt(x), big.NewInt(n), nil)
+	return nil
+}
@@ -0,0 +1,112 @@
+package parser
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/Yarik7610/expressive/lexer"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIntegerArithmetic(t *testing.T) {
+	nonErrorTests := []struct {
+		In  string
+		Out Value
+	}{
+		{In: "7 / 2", Out: Integer(3)},
+		{In: "-7 / 2", Out: Integer(-3)},
+		{In: "7 % 3", Out: Integer(1)},
+		{In: "-7 % 3", Out: Integer(-1)},
+		{In: "2^62 - 1 + 2^62", Out: Integer(9223372036854775807)},
+		{In: "-9223372036854775808", Out: Integer(-9223372036854775808)},
+		{In: "(-1)^-3", Out: Integer(-1)},
+		{In: "1e3 + 1_000", Out: Integer(2000)},
+		{In: "0x7FFF_FFFF_FFFF_FFFF - 0o7", Out: Integer(9223372036854775800)},
+		{In: "~(1 << 62) & 0xFF xor 0b1", Out: Integer(254)},
+		{In: "7 / 2 * 2 == 6", Out: Bool(true)},
+		{In: "max(2^62, 3) - min(-4, 2)", Out: Integer(4611686018427387908)},
+		{In: "sqrt(16) + abs(-2)", Out: Integer(6)},
+	}
+
+	errorTests := []struct {
+		In      string
+		Message string
+		Span    lexer.Span
+	}{
+		{
+			In:      "1 + 9223372036854775807 * 2",
+			Message: "9223372036854775807 * 2 overflows int64",
+			Span:    lexer.Span{Start: lexer.Position{Offset: 4, Line: 1, Column: 5}, End: lexer.Position{Offset: 27, Line: 1, Column: 28}},
+		},
+		{
+			In:      "2^64",
+			Message: "2 ^ 64 overflows int64",
+			Span:    lexer.Span{Start: lexer.Position{Offset: 0, Line: 1, Column: 1}, End: lexer.Position{Offset: 4, Line: 1, Column: 5}},
+		},
+		{
+			In:      "-(-9223372036854775808)",
+			Message: "-(-9223372036854775808) overflows int64",
+			Span:    lexer.Span{Start: lexer.Position{Offset: 0, Line: 1, Column: 1}, End: lexer.Position{Offset: 22, Line: 1, Column: 23}},
+		},
+		{
+			In:      "9223372036854775808",
+			Message: "number node error: 9223372036854775808 overflows int64",
+			Span:    lexer.Span{Start: lexer.Position{Offset: 0, Line: 1, Column: 1}, End: lexer.Position{Offset: 19, Line: 1, Column: 20}},
+		},
+		{
+			In:      "1 << 63",
+			Message: "9223372036854775808 overflows int64",
+			Span:    lexer.Span{Start: lexer.Position{Offset: 0, Line: 1, Column: 1}, End: lexer.Position{Offset: 7, Line: 1, Column: 8}},
+		},
+		{
+			In:      "1 / 0",
+			Message: "division by zero",
+			Span:    lexer.Span{Start: lexer.Position{Offset: 0, Line: 1, Column: 1}, End: lexer.Position{Offset: 5, Line: 1, Column: 6}},
+		},
+		{
+			In:      "2^-1",
+			Message: "2 ^ -1 isn't an integer",
+			Span:    lexer.Span{Start: lexer.Position{Offset: 0, Line: 1, Column: 1}, End: lexer.Position{Offset: 4, Line: 1, Column: 5}},
+		},
+		{
+			In:      "sqrt(2)",
+			Message: "1.4142135623730951 isn't an integer",
+			Span:    lexer.Span{Start: lexer.Position{Offset: 0, Line: 1, Column: 1}, End: lexer.Position{Offset: 7, Line: 1, Column: 8}},
+		},
+	}
+
+	newEnv := func() *Env {
+		env := NewEnv()
+		env.SetArithmetic(IntegerArithmetic{})
+		return env
+	}
+
+	for _, test := range nonErrorTests {
+		t.Run(test.In, func(t *testing.T) {
+			out, err := Eval(parseIntegers(t, test.In), newEnv())
+			assert.NoError(t, err)
+			assert.Equal(t, test.Out, out)
+		})
+	}
+
+	for _, test := range errorTests {
+		t.Run(test.In, func(t *testing.T) {
+			_, err := Eval(parseIntegers(t, test.In), newEnv())
+			var evalErr *EvalError
+			if assert.ErrorAs(t, err, &evalErr) {
+				assert.Equal(t, test.Message, evalErr.Message)
+				assert.Equal(t, test.Span, evalErr.Span)
+			}
+		})
+	}
+}
+
+func parseIntegers(t *testing.T, input string) []Node {
+	tokens, err := lexer.NewLexer(strings.NewReader(input)).Lex()
+	assert.NoError(t, err)
+	p := NewParser(tokens)
+	p.Mode = IntegerLiterals
+	nodes, err := p.Parse()
+	assert.NoError(t, err)
+	return nodes
+}
@@ -2,7 +2,7 @@ package parser
 
 import (
 	"fmt"
-	"math"
+	"slices"
 	"strconv"
 	"strings"
 
@@ -10,8 +10,9 @@ import (
 )
 
 type Node interface {
-	Eval() float64
+	Eval(env *Env) (Value, error)
 	String(spaceCount int) string
+	Span() lexer.Span
 }
 
 type NumberNode struct {
@@ -23,12 +24,236 @@ func (nn *NumberNode) String(spaceCount int) string {
 	return fmt.Sprint(spaceString, nn.Raw)
 }
 
-func (nn *NumberNode) Eval() float64 {
-	val, err := strconv.ParseFloat(nn.Raw, 64)
+func (nn *NumberNode) Span() lexer.Span {
+	return nn.Token.Span
+}
+
+func (nn *NumberNode) Eval(env *Env) (Value, error) {
+	if nn.Type == lexer.TOKEN_IMAGINARY {
+		arith, ok := env.arithmetic().(imaginaryLiterals)
+		if !ok {
+			return nil, &EvalError{fmt.Sprintf("imaginary number '%s' needs complex mode", nn.Raw), nn.Span()}
+		}
+		val, err := arith.Imaginary(nn.Raw)
+		if err != nil {
+			return nil, &EvalError{fmt.Sprintf("number node error: %s", err), nn.Span()}
+		}
+		return val, nil
+	}
+
+	val, err := env.arithmetic().Literal(nn.Raw)
+	if err != nil {
+		return nil, &EvalError{fmt.Sprintf("number node error: %s", err), nn.Span()}
+	}
+	return val, nil
+}
+
+// QuantityNode is a number with a unit, like 5 km or 9.8 m/s^2.
+type QuantityNode struct {
+	Number *NumberNode
+	Unit   UnitExpr
+}
+
+func (qn *QuantityNode) String(spaceCount int) string {
+	spaceString := strings.Repeat(" ", spaceCount)
+	return fmt.Sprint(spaceString, qn.Number.Raw, " ", qn.Unit)
+}
+
+func (qn *QuantityNode) Span() lexer.Span {
+	return lexer.Span{Start: qn.Number.Span().Start, End: qn.Unit.Span.End}
+}
+
+// Eval reads names after "*" and "/" that are variables as variables, like n
+// in 10 m / n. A name that is both a variable and a unit is an error, it's
+// unclear which one is meant.
+func (qn *QuantityNode) Eval(env *Env) (Value, error) {
+	val, err := qn.Number.Eval(env)
 	if err != nil {
-		panic(fmt.Sprintf("eval: number node error: %s", err))
+		return nil, err
+	}
+
+	units := UnitExpr{Terms: []UnitTerm{qn.Unit.Terms[0]}, Span: qn.Unit.Span}
+	variables := make([]UnitTerm, 0)
+	for _, term := range qn.Unit.Terms[1:] {
+		if _, ok := env.Get(term.Name.Raw); !ok {
+			units.Terms = append(units.Terms, term)
+			continue
+		}
+		if _, ok := env.unit(term.Name.Raw); ok {
+			return nil, &EvalError{fmt.Sprintf("'%s' is both a variable and a unit", term.Name.Raw), term.Name.Span}
+		}
+		variables = append(variables, term)
+	}
+
+	unit, err := evalUnit(env, units)
+	if err != nil {
+		return nil, err
+	}
+	val = newQuantity(val, unit)
+
+	arith := env.arithmetic()
+	for _, term := range variables {
+		variable, err := (&VariableNode{term.Name}).Eval(env)
+		if err != nil {
+			return nil, err
+		}
+
+		op := lexer.Token{Type: lexer.TOKEN_ASTERISK, Raw: "*"}
+		power := term.Power
+		if power < 0 {
+			op = lexer.Token{Type: lexer.TOKEN_SLASH, Raw: "/"}
+			power = -power
+		}
+		if power != 1 {
+			exponent, err := arith.Literal(strconv.Itoa(power))
+			if err == nil {
+				variable, err = binaryOp(arith, lexer.Token{Type: lexer.TOKEN_CARET, Raw: "^"}, variable, exponent)
+			}
+			if err != nil {
+				return nil, &EvalError{err.Error(), qn.Span()}
+			}
+		}
+
+		val, err = binaryOp(arith, op, val, variable)
+		if err != nil {
+			return nil, &EvalError{err.Error(), qn.Span()}
+		}
+	}
+	return val, nil
+}
+
+func evalUnit(env *Env, expr UnitExpr) (Unit, error) {
+	unit, err := resolveUnit(expr, env.unit)
+	if err, ok := err.(*unitError); ok {
+		return Unit{}, &EvalError{err.Error(), err.name.Span}
+	}
+	return unit, err
+}
+
+type VariableNode struct {
+	lexer.Token
+}
+
+func (vn *VariableNode) String(spaceCount int) string {
+	spaceString := strings.Repeat(" ", spaceCount)
+	return fmt.Sprint(spaceString, vn.Raw)
+}
+
+func (vn *VariableNode) Span() lexer.Span {
+	return vn.Token.Span
+}
+
+func (vn *VariableNode) Eval(env *Env) (Value, error) {
+	if val, ok, err := env.preciseConstant(vn.Raw); err != nil {
+		return nil, &EvalError{err.Error(), vn.Span()}
+	} else if ok {
+		return val, nil
 	}
-	return val
+
+	val, ok := env.Get(vn.Raw)
+	if !ok {
+		if val, ok := env.constant(vn.Raw); ok {
+			return val, nil
+		}
+		return nil, &EvalError{fmt.Sprintf("undefined variable '%s'", vn.Raw), vn.Span()}
+	}
+
+	//constants and variables set by the host are float64, they join the arithmetic in use
+	if number, ok := val.(Number); ok {
+		val, err := env.arithmetic().FromFloat(float64(number))
+		if err != nil {
+			return nil, &EvalError{fmt.Sprintf("'%s': %s", vn.Raw, err), vn.Span()}
+		}
+		return val, nil
+	}
+	return val, nil
+}
+
+type CallNode struct {
+	lexer.Token
+	Args  []Node
+	Close lexer.Token
+}
+
+func (cn *CallNode) String(spaceCount int) string {
+	spaceString := strings.Repeat(" ", spaceCount)
+	s := fmt.Sprint(spaceString, cn.Raw, "()")
+	for _, arg := range cn.Args {
+		s += fmt.Sprint("\n", spaceString, arg.String(spaceCount+1))
+	}
+	return s
+}
+
+func (cn *CallNode) Span() lexer.Span {
+	return lexer.Span{Start: cn.Token.Span.Start, End: cn.Close.Span.End}
+}
+
+func (cn *CallNode) Eval(env *Env) (Value, error) {
+	fn, ok := env.function(cn.Raw)
+	if !ok {
+		return nil, &EvalError{fmt.Sprintf("undefined function '%s'", cn.Raw), cn.Token.Span}
+	}
+	if err := fn.checkArity(cn.Raw, len(cn.Args)); err != nil {
+		return nil, &EvalError{err.Error(), cn.Span()}
+	}
+
+	values := make([]Value, 0, len(cn.Args))
+	for i, arg := range cn.Args {
+		val, err := arg.Eval(env)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := val.(Bool); ok {
+			return nil, &EvalError{fmt.Sprintf("%s expects numbers, argument %d is %s", cn.Raw, i+1, val.kind()), arg.Span()}
+		}
+		values = append(values, val)
+	}
+
+	//functions see numbers without units, the unit of the result is put back afterwards
+	var unit Unit
+	if slices.ContainsFunc(values, func(v Value) bool { _, ok := v.(Quantity); return ok }) {
+		var err error
+		values, unit, err = quantityArgs(cn.Raw, values, env.arithmetic())
+		if err != nil {
+			return nil, &EvalError{err.Error(), cn.Span()}
+		}
+	}
+
+	val, err := cn.call(env, fn, values)
+	if err != nil {
+		return nil, err
+	}
+	return newQuantity(val, unit), nil
+}
+
+func (cn *CallNode) call(env *Env, fn function, values []Value) (Value, error) {
+	if native, ok := env.native(cn.Raw); ok {
+		val, err := native(values)
+		if err != nil {
+			return nil, &EvalError{err.Error(), cn.Span()}
+		}
+		return val, nil
+	}
+
+	arith := env.arithmetic()
+	args := make([]float64, 0, len(values))
+	for i, val := range values {
+		arg, err := arith.Float(val)
+		if err != nil {
+			return nil, &EvalError{err.Error(), cn.Args[i].Span()}
+		}
+		args = append(args, arg)
+	}
+
+	result, err := fn.call(args...)
+	if err != nil {
+		return nil, &EvalError{err.Error(), cn.Span()}
+	}
+	val, err := arith.FromFloat(result)
+	if err != nil {
+		return nil, &EvalError{err.Error(), cn.Span()}
+	}
+	return val, nil
 }
 
 type BinaryNode struct {
@@ -42,23 +267,175 @@ func (bn *BinaryNode) String(spaceCount int) string {
 	return fmt.Sprint(spaceString, bn.Raw, "\n", spaceString, bn.Left.String(spaceCount+1), "\n", spaceString, bn.Right.String(spaceCount+1))
 }
 
-func (bn *BinaryNode) Eval() float64 {
-	switch bn.Token.Type {
-	case lexer.TOKEN_PLUS:
-		return bn.Left.Eval() + bn.Right.Eval()
-	case lexer.TOKEN_MINUS:
-		return bn.Left.Eval() - bn.Right.Eval()
-	case lexer.TOKEN_SLASH:
-		return bn.Left.Eval() / bn.Right.Eval()
-	case lexer.TOKEN_ASTERISK:
-		return bn.Left.Eval() * bn.Right.Eval()
-	case lexer.TOKEN_PERCENT:
-		return math.Mod(bn.Left.Eval(), bn.Right.Eval())
-	case lexer.TOKEN_CARET:
-		return math.Pow(bn.Left.Eval(), bn.Right.Eval())
-	default:
-		panic("eval: binary node error: undefined operator")
+func (bn *BinaryNode) Span() lexer.Span {
+	return lexer.Span{Start: bn.Left.Span().Start, End: bn.Right.Span().End}
+}
+
+func (bn *BinaryNode) Eval(env *Env) (Value, error) {
+	left, err := bn.Left.Eval(env)
+	if err != nil {
+		return nil, err
+	}
+	right, err := bn.Right.Eval(env)
+	if err != nil {
+		return nil, err
 	}
+
+	val, err := binaryOp(env.arithmetic(), bn.Token, left, right)
+	if err != nil {
+		return nil, &EvalError{err.Error(), bn.Span()}
+	}
+	return val, nil
+}
+
+// LogicalNode is "&&" or "||". Unlike BinaryNode it evaluates Right only when
+// Left doesn't decide the result on its own.
+type LogicalNode struct {
+	lexer.Token
+	Left  Node
+	Right Node
+}
+
+func (ln *LogicalNode) String(spaceCount int) string {
+	spaceString := strings.Repeat(" ", spaceCount)
+	return fmt.Sprint(spaceString, ln.Raw, "\n", spaceString, ln.Left.String(spaceCount+1), "\n", spaceString, ln.Right.String(spaceCount+1))
+}
+
+func (ln *LogicalNode) Span() lexer.Span {
+	return lexer.Span{Start: ln.Left.Span().Start, End: ln.Right.Span().End}
+}
+
+func (ln *LogicalNode) Eval(env *Env) (Value, error) {
+	left, err := ln.operand(ln.Left, env)
+	if err != nil {
+		return nil, err
+	}
+
+	if (ln.Token.Type == lexer.TOKEN_AND && !left) || (ln.Token.Type == lexer.TOKEN_OR && left) {
+		return left, nil
+	}
+	return ln.operand(ln.Right, env)
+}
+
+func (ln *LogicalNode) operand(node Node, env *Env) (Bool, error) {
+	val, err := node.Eval(env)
+	if err != nil {
+		return false, err
+	}
+
+	b, ok := val.(Bool)
+	if !ok {
+		return false, &EvalError{fmt.Sprintf("can't apply '%s' to %s", ln.Raw, val.kind()), node.Span()}
+	}
+	return b, nil
+}
+
+// ConditionalNode is c ? a : b or if(c, a, b). Only the branch selected by
+// Cond is evaluated.
+type ConditionalNode struct {
+	lexer.Token
+	Cond Node
+	Then Node
+	Else Node
+}
+
+func (cn *ConditionalNode) String(spaceCount int) string {
+	spaceString := strings.Repeat(" ", spaceCount)
+	return fmt.Sprint(spaceString, cn.Raw, "\n", spaceString, cn.Cond.String(spaceCount+1), "\n", spaceString, cn.Then.String(spaceCount+1), "\n", spaceString, cn.Else.String(spaceCount+1))
+}
+
+func (cn *ConditionalNode) Span() lexer.Span {
+	start := cn.Cond.Span().Start
+	if cn.Token.Type == lexer.TOKEN_IF {
+		start = cn.Token.Span.Start
+	}
+	return lexer.Span{Start: start, End: cn.Else.Span().End}
+}
+
+func (cn *ConditionalNode) Eval(env *Env) (Value, error) {
+	val, err := cn.Cond.Eval(env)
+	if err != nil {
+		return nil, err
+	}
+
+	cond, ok := val.(Bool)
+	if !ok {
+		return nil, &EvalError{fmt.Sprintf("condition must be bool, got %s", val.kind()), cn.Cond.Span()}
+	}
+
+	if cond {
+		return cn.Then.Eval(env)
+	}
+	return cn.Else.Eval(env)
+}
+
+// ConversionNode converts Value to Unit, like 60 mph in m/s or 5 km to m.
+type ConversionNode struct {
+	lexer.Token
+	Value Node
+	Unit  UnitExpr
+}
+
+func (cn *ConversionNode) String(spaceCount int) string {
+	spaceString := strings.Repeat(" ", spaceCount)
+	return fmt.Sprint(spaceString, cn.Raw, " ", cn.Unit, "\n", spaceString, cn.Value.String(spaceCount+1))
+}
+
+func (cn *ConversionNode) Span() lexer.Span {
+	return lexer.Span{Start: cn.Value.Span().Start, End: cn.Unit.Span.End}
+}
+
+func (cn *ConversionNode) Eval(env *Env) (Value, error) {
+	val, err := cn.Value.Eval(env)
+	if err != nil {
+		return nil, err
+	}
+	unit, err := evalUnit(env, cn.Unit)
+	if err != nil {
+		return nil, err
+	}
+
+	val, err = convert(env.arithmetic(), val, unit)
+	if err != nil {
+		return nil, &EvalError{err.Error(), cn.Span()}
+	}
+	return val, nil
+}
+
+// FormatNode is a directive like 255 in hex or 1/3 as fraction. It evaluates
+// to Value unchanged, hosts print the result with Options.
+type FormatNode struct {
+	lexer.Token
+	Value Node
+	Name  lexer.Token
+	// Close is the last token of the directive, Name or ')' after a precision.
+	Close    lexer.Token
+	Notation Notation
+	// Precision is -1 when the directive has none.
+	Precision int
+}
+
+func (fn *FormatNode) String(spaceCount int) string {
+	spaceString := strings.Repeat(" ", spaceCount)
+	return fmt.Sprint(spaceString, fn.Raw, " ", fn.Name.Raw, "\n", spaceString, fn.Value.String(spaceCount+1))
+}
+
+func (fn *FormatNode) Span() lexer.Span {
+	return lexer.Span{Start: fn.Value.Span().Start, End: fn.Close.Span.End}
+}
+
+func (fn *FormatNode) Eval(env *Env) (Value, error) {
+	return fn.Value.Eval(env)
+}
+
+// Options returns the format of the directive. Without a precision of its
+// own it takes the precision of defaults.
+func (fn *FormatNode) Options(defaults FormatOptions) FormatOptions {
+	opts := FormatOptions{Notation: fn.Notation, Precision: fn.Precision}
+	if opts.Precision < 0 {
+		opts.Precision = defaults.Precision
+	}
+	return opts
 }
 
 type UnaryNode struct {
@@ -71,6 +448,58 @@ func (un *UnaryNode) String(spaceCount int) string {
 	return fmt.Sprint(spaceString, un.Raw, "\n", spaceString, un.Right.String(spaceCount+1))
 }
 
-func (un *UnaryNode) Eval() float64 {
-	return -1 * un.Right.Eval()
+func (un *UnaryNode) Span() lexer.Span {
+	return lexer.Span{Start: un.Token.Span.Start, End: un.Right.Span().End}
+}
+
+func (un *UnaryNode) Eval(env *Env) (Value, error) {
+	//a negative literal is read as a whole, arithmetics that round literals may round it differently than its absolute value
+	if number, ok := un.Right.(*NumberNode); ok && number.Type == lexer.TOKEN_NUMBER && un.Token.Type == lexer.TOKEN_MINUS {
+		val, err := env.arithmetic().Literal("-" + number.Raw)
+		if err != nil {
+			return nil, &EvalError{fmt.Sprintf("number node error: %s", err), un.Span()}
+		}
+		return val, nil
+	}
+
+	right, err := un.Right.Eval(env)
+	if err != nil {
+		return nil, err
+	}
+
+	val, err := unaryOp(env.arithmetic(), un.Token, right)
+	if err != nil {
+		return nil, &EvalError{err.Error(), un.Span()}
+	}
+	return val, nil
+}
+
+type AssignNode struct {
+	lexer.Token
+	Name  lexer.Token
+	Value Node
+}
+
+func (an *AssignNode) String(spaceCount int) string {
+	spaceString := strings.Repeat(" ", spaceCount)
+	return fmt.Sprint(spaceString, an.Raw, "\n", spaceString, " ", an.Name.Raw, "\n", spaceString, an.Value.String(spaceCount+1))
+}
+
+func (an *AssignNode) Span() lexer.Span {
+	return lexer.Span{Start: an.Name.Span.Start, End: an.Value.Span().End}
+}
+
+func (an *AssignNode) Eval(env *Env) (Value, error) {
+	if env == nil {
+		return nil, &EvalError{fmt.Sprintf("can't assign '%s' without an environment", an.Name.Raw), an.Span()}
+	}
+
+	value, err := an.Value.Eval(env)
+	if err != nil {
+		return nil, err
+	}
+	if err := env.assign(an.Name.Raw, value); err != nil {
+		return nil, &EvalError{err.Error(), an.Name.Span}
+	}
+	return value, nil
 }
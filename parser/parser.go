@@ -2,98 +2,468 @@ package parser
 
 import (
 	"fmt"
+	"strconv"
 
 	"github.com/Yarik7610/expressive/lexer"
 )
 
-// EBNF grammar:
-// <expr> ::= <term>
-// <term> ::= <factor> (("+" | "-") <factor>)*
-// <factor> ::= <power> (("*" | "/" | "%") <power>)*
-// <power> ::= <unary> ("^" <unary>)*
-// <unary> ::= "-"? <unary> | <primary>
-// <primary> ::= NUMBER | "(" <expr> ")"
+// Statements are parsed by recursive descent:
+// <statement> ::= IDENT "=" <expr> | <expr>
+// <call> ::= IDENT "(" (<expr> ("," <expr>)*)? ")"
+// <directive> ::= <expr> ("in" | "as") IDENT ("(" NUMBER ")")?
+// <conversion> ::= <expr> ("in" | "to") <unit>
+// <quantity> ::= NUMBER <unit>
+// <unit> ::= <unit term> (("*" | "/") <unit term>)*
+// <unit term> ::= IDENT ("^" "-"? NUMBER)?
+//
+// A directive like 255 in hex ends a statement, it sets how the result is
+// printed. The directive of an assignment applies to the whole statement.
+// "in" followed by a name that isn't a format is a conversion like
+// 60 mph in m/s, which may appear anywhere.
+//
+// A unit right after a number takes the following "*" and "/" when a name
+// comes after them, so 9.8 m/s^2 is one quantity. A variable can't be one of
+// them, (5 km) * n multiplies by n.
+//
+// "if" is a keyword, if(c, a, b) looks like a call, but it's parsed into a
+// ConditionalNode, the same node as c ? a : b.
+//
+// Expressions are parsed by precedence climbing (Pratt parsing), driven by the
+// operator tables in grammar.go.
+//
+// Statements are separated by ";" or by a line break. Inside brackets an
+// expression may span several lines.
+
+// Mode is a set of flags that change how Parser reads its input.
+type Mode uint
+
+const (
+	// LegacyPrecedence evaluates "^" left to right and applies unary minus
+	// before it, so 2^3^2 is 64 and -2^2 is 4, like older versions did.
+	LegacyPrecedence Mode = 1 << iota
+	// IntegerLiterals rejects number literals that aren't integers, like 2.5
+	// or 1e-3, for evaluation with IntegerArithmetic.
+	IntegerLiterals
+)
 
 type Parser struct {
-	tokens []lexer.Token
-	pos    int
+	Mode    Mode
+	grammar *grammar
+	tokens  []lexer.Token
+	pos     int
+	//brackets holds positions of currently open brackets, line breaks don't end expressions inside them
+	brackets []int
 }
 
 func NewParser(tokens []lexer.Token) *Parser {
-	p := Parser{tokens, 0}
+	p := Parser{tokens: tokens}
 	return &p
 }
 
-func (p *Parser) Parse() []Node {
+// Parse returns every statement it managed to parse. When some of them are
+// broken, the returned error is an ErrorList holding one SyntaxError per
+// broken statement.
+func (p *Parser) Parse() ([]Node, error) {
 	nodes := make([]Node, 0)
+	var errs ErrorList
+
+	p.grammar = &standardGrammar
+	if p.Mode&LegacyPrecedence != 0 {
+		p.grammar = &legacyGrammar
+	}
 
 	for !p.isEnd() {
-		nodes = append(nodes, p.parseExpr())
+		if p.match(lexer.TOKEN_SEMICOLON) {
+			continue
+		}
+
+		node, err := p.parseStatement()
+		if err == nil {
+			err = p.requireEnd()
+		}
+		if err != nil {
+			syntaxErr := err.(*SyntaxError)
+			//a bracket that is never closed took the lines after it, parsing starts over after its line
+			if open, ok := p.unclosedBracket(); ok {
+				p.pos = open
+				syntaxErr = &SyntaxError{fmt.Sprintf("unclosed '%s'", p.tokens[open].Raw), p.tokens[open].Span}
+			}
+			errs = append(errs, syntaxErr)
+			p.synchronize(syntaxErr.Span.End.Line)
+			continue
+		}
+		nodes = append(nodes, node)
 	}
 
-	return nodes
+	if len(errs) > 0 {
+		return nodes, errs
+	}
+	return nodes, nil
 }
 
-func (p *Parser) parseExpr() Node {
-	return p.parseTerm()
+func (p *Parser) requireEnd() error {
+	if p.isEnd() || p.atLineBreak() || p.match(lexer.TOKEN_SEMICOLON) {
+		return nil
+	}
+
+	return &SyntaxError{fmt.Sprintf("expected end of statement, found %s", describe(p.peek())), p.peek().Span}
 }
 
-func (p *Parser) parseTerm() Node {
-	lhs := p.parseFactor()
+// synchronize skips the rest of a broken statement, up to the next ';' or
+// the end of the line where the error is. When the error is at the last token
+// of the line, and that token is already consumed, nothing is skipped.
+func (p *Parser) synchronize(line int) {
+	p.brackets = nil
 
-	for p.match(lexer.TOKEN_PLUS, lexer.TOKEN_MINUS) {
-		op := p.previous()
-		rhs := p.parseFactor()
-		lhs = &BinaryNode{Token: op, Left: lhs, Right: rhs}
+	for !p.isEnd() && p.peek().Span.Start.Line == line {
+		if p.advance().Type == lexer.TOKEN_SEMICOLON {
+			return
+		}
 	}
+}
 
-	return lhs
+// unclosedBracket returns the position of the outermost open bracket that has
+// no matching ')' up to the end of input.
+func (p *Parser) unclosedBracket() (int, bool) {
+	for _, open := range p.brackets {
+		depth := 0
+		for _, token := range p.tokens[open:] {
+			switch token.Type {
+			case lexer.TOKEN_BRACE_LEFT:
+				depth++
+			case lexer.TOKEN_BRACE_RIGHT:
+				depth--
+			}
+			if depth == 0 {
+				break
+			}
+		}
+		if depth > 0 {
+			return open, true
+		}
+	}
+	return 0, false
 }
 
-func (p *Parser) parseFactor() Node {
-	lhs := p.parsePower()
+func (p *Parser) parseStatement() (Node, error) {
+	if p.check(lexer.TOKEN_IDENT) && p.checkNext(lexer.TOKEN_ASSIGN) {
+		name := p.advance()
+		op := p.advance()
+		value, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
 
-	for p.match(lexer.TOKEN_ASTERISK, lexer.TOKEN_SLASH, lexer.TOKEN_PERCENT) {
-		op := p.previous()
-		rhs := p.parsePower()
-		lhs = &BinaryNode{Token: op, Left: lhs, Right: rhs}
+		if directive, ok := value.(*FormatNode); ok {
+			directive.Value = &AssignNode{Token: op, Name: name, Value: directive.Value}
+			return directive, nil
+		}
+		return &AssignNode{Token: op, Name: name, Value: value}, nil
 	}
 
-	return lhs
+	return p.parseExpr()
 }
 
-func (p *Parser) parsePower() Node {
-	lhs := p.parseUnary()
+func (p *Parser) parseExpr() (Node, error) {
+	return p.parseExpression(precLowest)
+}
 
-	for p.match(lexer.TOKEN_CARET) {
-		op := p.previous()
-		rhs := p.parseUnary()
-		lhs = &BinaryNode{Token: op, Left: lhs, Right: rhs}
+// parseExpression parses an expression up to the first operator that doesn't
+// bind tighter than precedence.
+func (p *Parser) parseExpression(precedence int) (Node, error) {
+	token := p.peek()
+	prefix, ok := p.grammar.prefix[token.Type]
+	if !ok {
+		return nil, &SyntaxError{fmt.Sprintf("expected number, variable or '(', found %s", describe(token)), token.Span}
 	}
+	p.advance()
+
+	left, err := prefix.parse(p, token)
+	if err != nil {
+		return nil, err
+	}
+
+	//operators can't start a new line outside of brackets, the line break already ended the expression
+	for !p.atLineBreak() {
+		op := p.peek()
+
+		//a directive ends the statement, an operator after it would lose the format
+		if directive, ok := left.(*FormatNode); ok {
+			if _, ok := p.grammar.infix[op.Type]; ok {
+				return nil, &SyntaxError{fmt.Sprintf("'%s' must end the statement, found %s", directive.Raw, describe(op)), op.Span}
+			}
+		}
 
-	return lhs
+		if infix, ok := p.grammar.infix[op.Type]; ok && infix.precedence > precedence {
+			p.advance()
+			left, err = infix.parse(p, op, left)
+		} else if postfix, ok := p.grammar.postfix[op.Type]; ok && postfix.precedence > precedence {
+			p.advance()
+			left, err = postfix.parse(p, op, left)
+		} else {
+			break
+		}
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return left, nil
+}
+
+func (p *Parser) parseNumber(token lexer.Token) (Node, error) {
+	if p.Mode&IntegerLiterals != 0 {
+		//literals too large for exact reading are left to the arithmetic to reject
+		if r, err := ratLiteral(token.Raw); err == nil && !r.IsInt() {
+			return nil, &SyntaxError{fmt.Sprintf("expected integer, found %s", describe(token)), token.Span}
+		}
+	}
+
+	if p.check(lexer.TOKEN_IDENT) && !p.checkNext(lexer.TOKEN_BRACE_LEFT) && !p.atLineBreak() {
+		unit, err := p.parseUnit()
+		if err != nil {
+			return nil, err
+		}
+		return &QuantityNode{Number: &NumberNode{token}, Unit: unit}, nil
+	}
+	return &NumberNode{token}, nil
+}
+
+func (p *Parser) parseUnit() (UnitExpr, error) {
+	start := p.peek().Span.Start
+	terms := make([]UnitTerm, 0, 1)
+	power := 1
+	for {
+		name := p.peek()
+		if name.Type != lexer.TOKEN_IDENT {
+			return UnitExpr{}, &SyntaxError{fmt.Sprintf("expected unit, found %s", describe(name)), name.Span}
+		}
+		p.advance()
+
+		term := UnitTerm{Name: name, Power: power}
+		if p.match(lexer.TOKEN_CARET) {
+			if p.match(lexer.TOKEN_MINUS) {
+				term.Power = -term.Power
+			}
+			exponent := p.peek()
+			n, err := strconv.Atoi(exponent.Raw)
+			if exponent.Type != lexer.TOKEN_NUMBER || err != nil || n == 0 {
+				return UnitExpr{}, &SyntaxError{fmt.Sprintf("expected integer power, found %s", describe(exponent)), exponent.Span}
+			}
+			if n > maxUnitPower {
+				return UnitExpr{}, &SyntaxError{fmt.Sprintf("unit power %d is too large, unit powers go up to %d", n, maxUnitPower), exponent.Span}
+			}
+			p.advance()
+			term.Power *= n
+		}
+		terms = append(terms, term)
+
+		//a name followed by '(' is a call, like in 5 m * sqrt(2)
+		next := p.peek()
+		isOp := next.Type == lexer.TOKEN_ASTERISK || next.Type == lexer.TOKEN_SLASH
+		if !isOp || p.atLineBreak() || !p.checkNext(lexer.TOKEN_IDENT) ||
+			(p.pos+2 < len(p.tokens) && p.tokens[p.pos+2].Type == lexer.TOKEN_BRACE_LEFT) {
+			break
+		}
+		p.advance()
+		power = 1
+		if next.Type == lexer.TOKEN_SLASH {
+			power = -1
+		}
+	}
+
+	return UnitExpr{Terms: terms, Span: lexer.Span{Start: start, End: p.previous().Span.End}}, nil
+}
+
+func (p *Parser) parseIdent(token lexer.Token) (Node, error) {
+	if p.check(lexer.TOKEN_BRACE_LEFT) {
+		return p.parseCall(token)
+	}
+	return &VariableNode{token}, nil
 }
 
-func (p *Parser) parseUnary() Node {
-	if p.match(lexer.TOKEN_MINUS) {
-		return &UnaryNode{Token: p.previous(), Right: p.parseUnary()}
+func (p *Parser) parseGroup(open lexer.Token) (Node, error) {
+	p.brackets = append(p.brackets, p.pos-1)
+	node, err := p.parseExpr()
+	if err != nil {
+		return nil, err
 	}
 
-	return p.parsePrimary()
+	if err := p.require(lexer.TOKEN_BRACE_RIGHT, fmt.Sprintf("expected ')' to close '(' opened at %s", open.Span.Start)); err != nil {
+		return nil, err
+	}
+	p.brackets = p.brackets[:len(p.brackets)-1]
+	return node, nil
 }
 
-func (p *Parser) parsePrimary() Node {
-	if p.match(lexer.TOKEN_NUMBER) {
-		return &NumberNode{p.previous()}
+func (p *Parser) parseUnary(op lexer.Token) (Node, error) {
+	right, err := p.parseExpression(p.grammar.prefix[op.Type].precedence)
+	if err != nil {
+		return nil, err
 	}
+	return &UnaryNode{Token: op, Right: right}, nil
+}
 
-	if p.match(lexer.TOKEN_BRACE_LEFT) {
-		node := p.parseExpr()
-		p.require(lexer.TOKEN_BRACE_RIGHT, "expected ')'")
-		return node
+func (p *Parser) parseBinary(op lexer.Token, left Node) (Node, error) {
+	rule := p.grammar.infix[op.Type]
+
+	//a right-associative operator lets an operator of the same precedence take its right operand
+	precedence := rule.precedence
+	if rule.rightAssoc {
+		precedence--
 	}
 
-	panic("parser: expected number or expression or '('")
+	right, err := p.parseExpression(precedence)
+	if err != nil {
+		return nil, err
+	}
+	return &BinaryNode{Token: op, Left: left, Right: right}, nil
+}
+
+func (p *Parser) parseConditional(op lexer.Token, cond Node) (Node, error) {
+	//a directive would end the statement before ':'
+	then, err := p.parseExpression(precDirective)
+	if err != nil {
+		return nil, err
+	}
+	if err := p.require(lexer.TOKEN_COLON, fmt.Sprintf("expected ':' to match '?' at %s", op.Span.Start)); err != nil {
+		return nil, err
+	}
+
+	//right-associative, so a ? b : c ? d : e is a ? b : (c ? d : e)
+	otherwise, err := p.parseExpression(precConditional - 1)
+	if err != nil {
+		return nil, err
+	}
+	return &ConditionalNode{Token: op, Cond: cond, Then: then, Else: otherwise}, nil
+}
+
+func (p *Parser) parseLogical(op lexer.Token, left Node) (Node, error) {
+	right, err := p.parseExpression(p.grammar.infix[op.Type].precedence)
+	if err != nil {
+		return nil, err
+	}
+	return &LogicalNode{Token: op, Left: left, Right: right}, nil
+}
+
+func (p *Parser) parseDirective(op lexer.Token, left Node) (Node, error) {
+	name := p.peek()
+	notation, err := ParseNotation(name.Raw)
+	if op.Type == lexer.TOKEN_IN && (name.Type != lexer.TOKEN_IDENT || err != nil) {
+		return p.parseConversion(op, left)
+	}
+
+	if len(p.brackets) > 0 {
+		return nil, &SyntaxError{fmt.Sprintf("'%s' must end the statement, it can't be inside brackets", op.Raw), op.Span}
+	}
+	if _, ok := left.(*FormatNode); ok {
+		return nil, &SyntaxError{fmt.Sprintf("the result already has a format, found '%s'", op.Raw), op.Span}
+	}
+	if name.Type != lexer.TOKEN_IDENT {
+		return nil, &SyntaxError{fmt.Sprintf("expected format after '%s', found %s", op.Raw, describe(name)), name.Span}
+	}
+	p.advance()
+	if err != nil {
+		return nil, &SyntaxError{err.Error(), name.Span}
+	}
+
+	node := &FormatNode{Token: op, Value: left, Name: name, Close: name, Notation: notation, Precision: -1}
+	if !p.check(lexer.TOKEN_BRACE_LEFT) {
+		return node, nil
+	}
+
+	open := p.advance()
+	if notation != Fixed && notation != Scientific && notation != Engineering {
+		return nil, &SyntaxError{fmt.Sprintf("%s format has no precision", notation), open.Span}
+	}
+	digits := p.peek()
+	precision, err := strconv.Atoi(digits.Raw)
+	if digits.Type != lexer.TOKEN_NUMBER || err != nil {
+		return nil, &SyntaxError{fmt.Sprintf("expected number of digits, found %s", describe(digits)), digits.Span}
+	}
+	if precision > MaxPrecision {
+		return nil, &SyntaxError{fmt.Sprintf("%d digits is more than %d", precision, MaxPrecision), digits.Span}
+	}
+	p.advance()
+	if err := p.require(lexer.TOKEN_BRACE_RIGHT, fmt.Sprintf("expected ')' to close '(' opened at %s", open.Span.Start)); err != nil {
+		return nil, err
+	}
+
+	node.Precision = precision
+	node.Close = p.previous()
+	return node, nil
+}
+
+func (p *Parser) parseConversion(op lexer.Token, left Node) (Node, error) {
+	if _, ok := left.(*FormatNode); ok {
+		return nil, &SyntaxError{fmt.Sprintf("the result already has a format, found '%s'", op.Raw), op.Span}
+	}
+
+	name := p.peek()
+	if name.Type != lexer.TOKEN_IDENT {
+		expected := "unit"
+		if op.Type == lexer.TOKEN_IN {
+			expected = "format or unit"
+		}
+		return nil, &SyntaxError{fmt.Sprintf("expected %s after '%s', found %s", expected, op.Raw, describe(name)), name.Span}
+	}
+
+	unit, err := p.parseUnit()
+	if err != nil {
+		return nil, err
+	}
+	return &ConversionNode{Token: op, Value: left, Unit: unit}, nil
+}
+
+func (p *Parser) parseIf(token lexer.Token) (Node, error) {
+	if !p.check(lexer.TOKEN_BRACE_LEFT) {
+		return nil, &SyntaxError{fmt.Sprintf("expected '(' after 'if', found %s", describe(p.peek())), p.peek().Span}
+	}
+
+	args, err := p.parseArgs()
+	if err != nil {
+		return nil, err
+	}
+	if len(args) != 3 {
+		return nil, &SyntaxError{fmt.Sprintf("if expects 3 arguments, got %d", len(args)), lexer.Span{Start: token.Span.Start, End: p.previous().Span.End}}
+	}
+	return &ConditionalNode{Token: token, Cond: args[0], Then: args[1], Else: args[2]}, nil
+}
+
+func (p *Parser) parseCall(name lexer.Token) (Node, error) {
+	args, err := p.parseArgs()
+	if err != nil {
+		return nil, err
+	}
+	return &CallNode{Token: name, Args: args, Close: p.previous()}, nil
+}
+
+// parseArgs parses the bracketed arguments of a call, starting at '('.
+func (p *Parser) parseArgs() ([]Node, error) {
+	p.brackets = append(p.brackets, p.pos)
+	open := p.advance()
+
+	args := make([]Node, 0)
+	if !p.check(lexer.TOKEN_BRACE_RIGHT) {
+		for {
+			arg, err := p.parseExpr()
+			if err != nil {
+				return nil, err
+			}
+			args = append(args, arg)
+
+			if !p.match(lexer.TOKEN_COMMA) {
+				break
+			}
+		}
+	}
+
+	if err := p.require(lexer.TOKEN_BRACE_RIGHT, fmt.Sprintf("expected ',' or ')' to close '(' opened at %s", open.Span.Start)); err != nil {
+		return nil, err
+	}
+	p.brackets = p.brackets[:len(p.brackets)-1]
+	return args, nil
 }
 
 func (p *Parser) match(tokenTypes ...int) bool {
@@ -107,19 +477,27 @@ func (p *Parser) match(tokenTypes ...int) bool {
 	return false
 }
 
-func (p *Parser) require(tokenType int, errorMessage string) {
+func (p *Parser) atLineBreak() bool {
+	return len(p.brackets) == 0 && p.pos > 0 && p.peek().Span.Start.Line > p.previous().Span.End.Line
+}
+
+func (p *Parser) require(tokenType int, errorMessage string) error {
 	if p.check(tokenType) {
 		p.advance()
-		return
+		return nil
 	}
 
-	panic(fmt.Sprintf("parser: %s", errorMessage))
+	return &SyntaxError{errorMessage, p.peek().Span}
 }
 
 func (p *Parser) check(tokenType int) bool {
 	return p.peek().Type == tokenType
 }
 
+func (p *Parser) checkNext(tokenType int) bool {
+	return p.pos+1 < len(p.tokens) && p.tokens[p.pos+1].Type == tokenType
+}
+
 func (p *Parser) advance() lexer.Token {
 	if !p.isEnd() {
 		p.pos++
@@ -128,6 +506,14 @@ func (p *Parser) advance() lexer.Token {
 }
 
 func (p *Parser) peek() lexer.Token {
+	if p.pos >= len(p.tokens) {
+		end := lexer.Span{}
+		if len(p.tokens) > 0 {
+			end.Start = p.tokens[len(p.tokens)-1].Span.End
+			end.End = end.Start
+		}
+		return lexer.Token{Type: lexer.TOKEN_EOF, Raw: "TOKEN_EOF", Span: end}
+	}
 	return p.tokens[p.pos]
 }
 
@@ -136,7 +522,14 @@ func (p *Parser) previous() lexer.Token {
 }
 
 func (p *Parser) isEnd() bool {
-	return p.pos == len(p.tokens) || p.peek().Type == lexer.TOKEN_EOF
+	return p.peek().Type == lexer.TOKEN_EOF
+}
+
+func describe(token lexer.Token) string {
+	if token.Type == lexer.TOKEN_EOF {
+		return "end of input"
+	}
+	return fmt.Sprintf("'%s'", token.Raw)
 }
 
 func PrintNodes(nodes []Node) {
@@ -1,6 +1,8 @@
 package parser
 
 import (
+	"maps"
+	"strings"
 	"testing"
 
 	"github.com/Yarik7610/expressive/lexer"
@@ -8,7 +10,7 @@ import (
 )
 
 func TestParser(t *testing.T) {
-	nonPanicTests := []struct {
+	nonErrorTests := []struct {
 		Name string
 		In   []lexer.Token
 		Out  []Node
@@ -166,9 +168,127 @@ func TestParser(t *testing.T) {
 				},
 			},
 		},
+		{
+			Name: "variables",
+			In: []lexer.Token{
+				{Type: lexer.TOKEN_IDENT, Raw: "rate"},
+				{Type: lexer.TOKEN_ASTERISK, Raw: "*"},
+				{Type: lexer.TOKEN_BRACE_LEFT, Raw: "("},
+				{Type: lexer.TOKEN_IDENT, Raw: "x"},
+				{Type: lexer.TOKEN_MINUS, Raw: "-"},
+				{Type: lexer.TOKEN_NUMBER, Raw: "1"},
+				{Type: lexer.TOKEN_BRACE_RIGHT, Raw: ")"},
+				{Type: lexer.TOKEN_EOF, Raw: "TOKEN_EOF"},
+			},
+			Out: []Node{
+				&BinaryNode{
+					Token: lexer.Token{Type: lexer.TOKEN_ASTERISK, Raw: "*"},
+					Left:  &VariableNode{Token: lexer.Token{Type: lexer.TOKEN_IDENT, Raw: "rate"}},
+					Right: &BinaryNode{
+						Token: lexer.Token{Type: lexer.TOKEN_MINUS, Raw: "-"},
+						Left:  &VariableNode{Token: lexer.Token{Type: lexer.TOKEN_IDENT, Raw: "x"}},
+						Right: &NumberNode{Token: lexer.Token{Type: lexer.TOKEN_NUMBER, Raw: "1"}},
+					},
+				},
+			},
+		},
+		{
+			Name: "assignment",
+			In: []lexer.Token{
+				{Type: lexer.TOKEN_IDENT, Raw: "x"},
+				{Type: lexer.TOKEN_ASSIGN, Raw: "="},
+				{Type: lexer.TOKEN_NUMBER, Raw: "2"},
+				{Type: lexer.TOKEN_ASTERISK, Raw: "*"},
+				{Type: lexer.TOKEN_IDENT, Raw: "y"},
+				{Type: lexer.TOKEN_EOF, Raw: "TOKEN_EOF"},
+			},
+			Out: []Node{
+				&AssignNode{
+					Token: lexer.Token{Type: lexer.TOKEN_ASSIGN, Raw: "="},
+					Name:  lexer.Token{Type: lexer.TOKEN_IDENT, Raw: "x"},
+					Value: &BinaryNode{
+						Token: lexer.Token{Type: lexer.TOKEN_ASTERISK, Raw: "*"},
+						Left:  &NumberNode{Token: lexer.Token{Type: lexer.TOKEN_NUMBER, Raw: "2"}},
+						Right: &VariableNode{Token: lexer.Token{Type: lexer.TOKEN_IDENT, Raw: "y"}},
+					},
+				},
+			},
+		},
+		{
+			Name: "function call",
+			In: []lexer.Token{
+				{Type: lexer.TOKEN_IDENT, Raw: "max"},
+				{Type: lexer.TOKEN_BRACE_LEFT, Raw: "("},
+				{Type: lexer.TOKEN_NUMBER, Raw: "1"},
+				{Type: lexer.TOKEN_COMMA, Raw: ","},
+				{Type: lexer.TOKEN_IDENT, Raw: "x"},
+				{Type: lexer.TOKEN_PLUS, Raw: "+"},
+				{Type: lexer.TOKEN_NUMBER, Raw: "2"},
+				{Type: lexer.TOKEN_BRACE_RIGHT, Raw: ")"},
+				{Type: lexer.TOKEN_EOF, Raw: "TOKEN_EOF"},
+			},
+			Out: []Node{
+				&CallNode{
+					Token: lexer.Token{Type: lexer.TOKEN_IDENT, Raw: "max"},
+					Args: []Node{
+						&NumberNode{Token: lexer.Token{Type: lexer.TOKEN_NUMBER, Raw: "1"}},
+						&BinaryNode{
+							Token: lexer.Token{Type: lexer.TOKEN_PLUS, Raw: "+"},
+							Left:  &VariableNode{Token: lexer.Token{Type: lexer.TOKEN_IDENT, Raw: "x"}},
+							Right: &NumberNode{Token: lexer.Token{Type: lexer.TOKEN_NUMBER, Raw: "2"}},
+						},
+					},
+					Close: lexer.Token{Type: lexer.TOKEN_BRACE_RIGHT, Raw: ")"},
+				},
+			},
+		},
+		{
+			Name: "function call without arguments",
+			In: []lexer.Token{
+				{Type: lexer.TOKEN_IDENT, Raw: "f"},
+				{Type: lexer.TOKEN_BRACE_LEFT, Raw: "("},
+				{Type: lexer.TOKEN_BRACE_RIGHT, Raw: ")"},
+				{Type: lexer.TOKEN_EOF, Raw: "TOKEN_EOF"},
+			},
+			Out: []Node{
+				&CallNode{
+					Token: lexer.Token{Type: lexer.TOKEN_IDENT, Raw: "f"},
+					Args:  []Node{},
+					Close: lexer.Token{Type: lexer.TOKEN_BRACE_RIGHT, Raw: ")"},
+				},
+			},
+		},
+		{
+			Name: "nested ternary",
+			In: []lexer.Token{
+				{Type: lexer.TOKEN_IDENT, Raw: "a"},
+				{Type: lexer.TOKEN_QUESTION, Raw: "?"},
+				{Type: lexer.TOKEN_NUMBER, Raw: "1"},
+				{Type: lexer.TOKEN_COLON, Raw: ":"},
+				{Type: lexer.TOKEN_IDENT, Raw: "b"},
+				{Type: lexer.TOKEN_QUESTION, Raw: "?"},
+				{Type: lexer.TOKEN_NUMBER, Raw: "2"},
+				{Type: lexer.TOKEN_COLON, Raw: ":"},
+				{Type: lexer.TOKEN_NUMBER, Raw: "3"},
+				{Type: lexer.TOKEN_EOF, Raw: "TOKEN_EOF"},
+			},
+			Out: []Node{
+				&ConditionalNode{
+					Token: lexer.Token{Type: lexer.TOKEN_QUESTION, Raw: "?"},
+					Cond:  &VariableNode{Token: lexer.Token{Type: lexer.TOKEN_IDENT, Raw: "a"}},
+					Then:  &NumberNode{Token: lexer.Token{Type: lexer.TOKEN_NUMBER, Raw: "1"}},
+					Else: &ConditionalNode{
+						Token: lexer.Token{Type: lexer.TOKEN_QUESTION, Raw: "?"},
+						Cond:  &VariableNode{Token: lexer.Token{Type: lexer.TOKEN_IDENT, Raw: "b"}},
+						Then:  &NumberNode{Token: lexer.Token{Type: lexer.TOKEN_NUMBER, Raw: "2"}},
+						Else:  &NumberNode{Token: lexer.Token{Type: lexer.TOKEN_NUMBER, Raw: "3"}},
+					},
+				},
+			},
+		},
 	}
 
-	panicTests := []struct {
+	errorTests := []struct {
 		Name string
 		In   []lexer.Token
 	}{
@@ -203,6 +323,56 @@ func TestParser(t *testing.T) {
 				{Type: lexer.TOKEN_EOF, Raw: "TOKEN_EOF"},
 			},
 		},
+		{
+			Name: "assignment to a number",
+			In: []lexer.Token{
+				{Type: lexer.TOKEN_NUMBER, Raw: "1"},
+				{Type: lexer.TOKEN_ASSIGN, Raw: "="},
+				{Type: lexer.TOKEN_NUMBER, Raw: "2"},
+				{Type: lexer.TOKEN_EOF, Raw: "TOKEN_EOF"},
+			},
+		},
+		{
+			Name: "trailing comma in call",
+			In: []lexer.Token{
+				{Type: lexer.TOKEN_IDENT, Raw: "sin"},
+				{Type: lexer.TOKEN_BRACE_LEFT, Raw: "("},
+				{Type: lexer.TOKEN_NUMBER, Raw: "1"},
+				{Type: lexer.TOKEN_COMMA, Raw: ","},
+				{Type: lexer.TOKEN_BRACE_RIGHT, Raw: ")"},
+				{Type: lexer.TOKEN_EOF, Raw: "TOKEN_EOF"},
+			},
+		},
+		{
+			Name: "ternary without ':'",
+			In: []lexer.Token{
+				{Type: lexer.TOKEN_IDENT, Raw: "c"},
+				{Type: lexer.TOKEN_QUESTION, Raw: "?"},
+				{Type: lexer.TOKEN_NUMBER, Raw: "1"},
+				{Type: lexer.TOKEN_EOF, Raw: "TOKEN_EOF"},
+			},
+		},
+		{
+			Name: "if with two arguments",
+			In: []lexer.Token{
+				{Type: lexer.TOKEN_IF, Raw: "if"},
+				{Type: lexer.TOKEN_BRACE_LEFT, Raw: "("},
+				{Type: lexer.TOKEN_IDENT, Raw: "c"},
+				{Type: lexer.TOKEN_COMMA, Raw: ","},
+				{Type: lexer.TOKEN_NUMBER, Raw: "1"},
+				{Type: lexer.TOKEN_BRACE_RIGHT, Raw: ")"},
+				{Type: lexer.TOKEN_EOF, Raw: "TOKEN_EOF"},
+			},
+		},
+		{
+			Name: "if assigned",
+			In: []lexer.Token{
+				{Type: lexer.TOKEN_IF, Raw: "if"},
+				{Type: lexer.TOKEN_ASSIGN, Raw: "="},
+				{Type: lexer.TOKEN_NUMBER, Raw: "1"},
+				{Type: lexer.TOKEN_EOF, Raw: "TOKEN_EOF"},
+			},
+		},
 		{
 			Name: "no second operand",
 			In: []lexer.Token{
@@ -213,18 +383,215 @@ func TestParser(t *testing.T) {
 		},
 	}
 
-	for _, test := range nonPanicTests {
+	for _, test := range nonErrorTests {
 		t.Run(test.Name, func(t *testing.T) {
-			out := NewParser(test.In).Parse()
+			out, err := NewParser(test.In).Parse()
+			assert.NoError(t, err)
 			assert.EqualValues(t, test.Out, out)
 		})
 	}
 
-	for _, test := range panicTests {
+	for _, test := range errorTests {
 		t.Run(test.Name, func(t *testing.T) {
-			assert.Panics(t, func() {
-				NewParser(test.In).Parse()
-			})
+			_, err := NewParser(test.In).Parse()
+			var syntaxErr *SyntaxError
+			assert.ErrorAs(t, err, &syntaxErr)
+		})
+	}
+}
+
+func TestParserSpans(t *testing.T) {
+	tokens, err := lexer.NewLexer(strings.NewReader("1 + -23 * 4")).Lex()
+	assert.NoError(t, err)
+
+	out, err := NewParser(tokens).Parse()
+	assert.NoError(t, err)
+
+	root := out[0].(*BinaryNode)
+	assert.Equal(t, lexer.Span{Start: lexer.Position{Offset: 0, Line: 1, Column: 1}, End: lexer.Position{Offset: 11, Line: 1, Column: 12}}, root.Span())
+
+	product := root.Right.(*BinaryNode)
+	assert.Equal(t, lexer.Span{Start: lexer.Position{Offset: 4, Line: 1, Column: 5}, End: lexer.Position{Offset: 11, Line: 1, Column: 12}}, product.Span())
+
+	negation := product.Left.(*UnaryNode)
+	assert.Equal(t, lexer.Span{Start: lexer.Position{Offset: 4, Line: 1, Column: 5}, End: lexer.Position{Offset: 7, Line: 1, Column: 8}}, negation.Span())
+
+	tokens, err = lexer.NewLexer(strings.NewReader("(1 +\n2")).Lex()
+	assert.NoError(t, err)
+
+	_, err = NewParser(tokens).Parse()
+	var syntaxErr *SyntaxError
+	if assert.ErrorAs(t, err, &syntaxErr) {
+		assert.Equal(t, "unclosed '('", syntaxErr.Message)
+		assert.Equal(t, lexer.Position{Offset: 0, Line: 1, Column: 1}, syntaxErr.Span.Start)
+	}
+}
+
+func TestParserRecovery(t *testing.T) {
+	tests := []struct {
+		Name   string
+		In     string
+		Nodes  int
+		Errors []lexer.Position
+	}{
+		{
+			Name:  "expressions separated by ';' and line breaks",
+			In:    "1 + 2; 3\n4 *\n5\n(6\n- 7)",
+			Nodes: 4,
+		},
+		{
+			Name:   "binary operator on the next line ends the expression",
+			In:     "1\n* 2",
+			Nodes:  1,
+			Errors: []lexer.Position{{Offset: 2, Line: 2, Column: 1}},
+		},
+		{
+			Name:  "every broken line is reported",
+			In:    "1 +\n2 2\n3 * 4\n(5 + 6\n7 ^ 8; ) + 1; 9",
+			Nodes: 2,
+			Errors: []lexer.Position{
+				{Offset: 6, Line: 2, Column: 3},
+				{Offset: 21, Line: 5, Column: 1},
+				{Offset: 28, Line: 5, Column: 8},
+			},
+		},
+		{
+			Name:   "unclosed bracket doesn't take the next line",
+			In:     "2 * (3\n4; 5 * 2",
+			Nodes:  2,
+			Errors: []lexer.Position{{Offset: 4, Line: 1, Column: 5}},
+		},
+		{
+			Name:   "unclosed bracket spanning lines",
+			In:     "(1 +\n2\n3",
+			Nodes:  2,
+			Errors: []lexer.Position{{Offset: 0, Line: 1, Column: 1}},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			tokens, err := lexer.NewLexer(strings.NewReader(test.In)).Lex()
+			assert.NoError(t, err)
+
+			out, err := NewParser(tokens).Parse()
+			assert.Len(t, out, test.Nodes)
+
+			if len(test.Errors) == 0 {
+				assert.NoError(t, err)
+				return
+			}
+
+			var errs ErrorList
+			if assert.ErrorAs(t, err, &errs) {
+				positions := make([]lexer.Position, 0, len(errs))
+				for _, syntaxErr := range errs {
+					positions = append(positions, syntaxErr.Span.Start)
+				}
+				assert.Equal(t, test.Errors, positions)
+			}
+		})
+	}
+}
+
+func TestParserIntegerLiterals(t *testing.T) {
+	tokens, err := lexer.NewLexer(strings.NewReader("1_000 + 1e3 + 2.0\nx = 2.5\n1e-3")).Lex()
+	assert.NoError(t, err)
+
+	p := NewParser(tokens)
+	p.Mode = IntegerLiterals
+	out, err := p.Parse()
+	assert.Len(t, out, 1)
+
+	var errs ErrorList
+	if assert.ErrorAs(t, err, &errs) && assert.Len(t, errs, 2) {
+		assert.Equal(t, "expected integer, found '2.5'", errs[0].Message)
+		assert.Equal(t, lexer.Position{Offset: 22, Line: 2, Column: 5}, errs[0].Span.Start)
+		assert.Equal(t, "expected integer, found '1e-3'", errs[1].Message)
+	}
+}
+
+func TestGrammarPostfix(t *testing.T) {
+	//"%" as a postfix operator, binding tighter than "*" but looser than "^"
+	g := grammar{
+		prefix:  standardGrammar.prefix,
+		infix:   maps.Clone(standardGrammar.infix),
+		postfix: map[int]postfixRule{},
+	}
+	delete(g.infix, lexer.TOKEN_PERCENT)
+	g.postfix[lexer.TOKEN_PERCENT] = postfixRule{precUnary, func(p *Parser, op lexer.Token, left Node) (Node, error) {
+		return &UnaryNode{Token: op, Right: left}, nil
+	}}
+
+	tokens, err := lexer.NewLexer(strings.NewReader("2 * 3^2% %")).Lex()
+	assert.NoError(t, err)
+
+	p := NewParser(tokens)
+	p.grammar = &g
+	out, err := p.parseExpr()
+	assert.NoError(t, err)
+
+	assert.Equal(t, "*\n 2\n %\n   %\n     ^\n       3\n       2", out.String(0))
+}
+
+func TestParserDirectives(t *testing.T) {
+	nonErrorTests := []struct {
+		In        string
+		Tree      string
+		Notation  Notation
+		Precision int
+	}{
+		{In: "255 in hex", Tree: "in hex\n 255", Notation: Hex, Precision: -1},
+		{In: "1/3 as fraction", Tree: "as fraction\n /\n   1\n   3", Notation: Fraction, Precision: -1},
+		{In: "pi in decimal(3)", Tree: "in decimal\n pi", Notation: Fixed, Precision: 3},
+		{In: "x = 255 in bin", Tree: "in bin\n =\n  x\n   255", Notation: Binary, Precision: -1},
+		{In: "1 < 2 ? 10 : 11 in oct", Tree: "in oct\n ?\n   <\n     1\n     2\n   10\n   11", Notation: Octal, Precision: -1},
+	}
+
+	errorTests := []struct {
+		In      string
+		Message string
+	}{
+		{In: "(255 in hex) + 1", Message: "'in' must end the statement, it can't be inside brackets"},
+		{In: "max(1 as fraction, 2)", Message: "'as' must end the statement, it can't be inside brackets"},
+		{In: "1 in hex in bin", Message: "the result already has a format, found 'in'"},
+		{In: "255 in hex + 1", Message: "'in' must end the statement, found '+'"},
+		{In: "1 in hex == 255", Message: "'in' must end the statement, found '=='"},
+		{In: "x = 1/3 as fraction * 3", Message: "'as' must end the statement, found '*'"},
+		{In: "1 as roman", Message: "unknown format 'roman'"},
+		{In: "1 as 2", Message: "expected format after 'as', found '2'"},
+		{In: "1 in 2", Message: "expected format or unit after 'in', found '2'"},
+		{In: "1 in hex(2)", Message: "hex format has no precision"},
+		{In: "1 in decimal(2.5)", Message: "expected number of digits, found '2.5'"},
+		{In: "1 in scientific(999999999)", Message: "999999999 digits is more than 1000"},
+		{In: "1 in decimal(99999999999999999999)", Message: "expected number of digits, found '99999999999999999999'"},
+	}
+
+	for _, test := range nonErrorTests {
+		t.Run(test.In, func(t *testing.T) {
+			tokens, err := lexer.NewLexer(strings.NewReader(test.In)).Lex()
+			assert.NoError(t, err)
+
+			out, err := NewParser(tokens).Parse()
+			if assert.NoError(t, err) && assert.Len(t, out, 1) {
+				assert.Equal(t, test.Tree, out[0].String(0))
+				directive := out[0].(*FormatNode)
+				assert.Equal(t, test.Notation, directive.Notation)
+				assert.Equal(t, test.Precision, directive.Precision)
+			}
+		})
+	}
+
+	for _, test := range errorTests {
+		t.Run(test.In, func(t *testing.T) {
+			tokens, err := lexer.NewLexer(strings.NewReader(test.In)).Lex()
+			assert.NoError(t, err)
+
+			_, err = NewParser(tokens).Parse()
+			var errs ErrorList
+			if assert.ErrorAs(t, err, &errs) && assert.Len(t, errs, 1) {
+				assert.Equal(t, test.Message, errs[0].Message)
+			}
 		})
 	}
 }
@@ -0,0 +1,393 @@
+package parser
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"github.com/Yarik7610/expressive/lexer"
+)
+
+// maxRationalBits bounds numerators and denominators made by "^" and by
+// exponents of literals, 1e1000000000 would take all the memory otherwise.
+const maxRationalBits = 1 << 22
+
+// RationalArithmetic evaluates with exact fractions: 0.1 is 1/10 and 1/3+1/6
+// is 1/2. Results that aren't rational are errors, not approximations: "^"
+// with a fractional exponent works only when the root is exact, like
+// 8^(1/3), and sin, exp and other irrational built-in functions can't be
+// called. abs, floor, ceil, round, trunc, min, max, re, im, conj and exact
+// sqrt are supported.
+//
+// float64 values of constants, host variables and registered functions are
+// converted by their shortest decimal form, so 0.1 from the host is 1/10 too.
+type RationalArithmetic struct{}
+
+// Rational is a number of RationalArithmetic.
+type Rational struct {
+	r *big.Rat
+}
+
+// String prints r as a fraction, or as an integer when its denominator is 1.
+func (r Rational) String() string {
+	return r.r.RatString()
+}
+
+// FloatString prints r as a decimal with places digits after the point, the
+// last digit rounded half away from zero.
+func (r Rational) FloatString(places int) string {
+	return r.r.FloatString(places)
+}
+
+func (r Rational) kind() string {
+	return "number"
+}
+
+// Big returns a copy of the value of r.
+func (r Rational) Big() *big.Rat {
+	return new(big.Rat).Set(r.r)
+}
+
+func (RationalArithmetic) Literal(raw string) (Value, error) {
+	r, err := ratLiteral(raw)
+	if err != nil {
+		return nil, err
+	}
+	return Rational{r}, nil
+}
+
+func (RationalArithmetic) FromFloat(f float64) (Value, error) {
+	r, err := ratFromFloat(f)
+	if err != nil {
+		return nil, err
+	}
+	return Rational{r}, nil
+}
+
+// precise rejects irrational constants, their decimal forms would silently
+// make results inexact.
+func (RationalArithmetic) precise(name string) (Value, bool, error) {
+	switch name {
+	case "pi", "e", "tau", "phi":
+		return nil, false, fmt.Errorf("%s is irrational, it has no exact value in rational mode", name)
+	}
+	return nil, false, nil
+}
+
+func (a RationalArithmetic) Float(v Value) (float64, error) {
+	r, err := a.operand(v)
+	if err != nil {
+		return 0, err
+	}
+	f, _ := r.Float64()
+	return f, nil
+}
+
+func (a RationalArithmetic) Binary(op lexer.Token, left Value, right Value) (Value, error) {
+	l, err := a.operand(left)
+	if err != nil {
+		return nil, err
+	}
+	r, err := a.operand(right)
+	if err != nil {
+		return nil, err
+	}
+
+	z := new(big.Rat)
+	switch op.Type {
+	case lexer.TOKEN_PLUS:
+		z.Add(l, r)
+	case lexer.TOKEN_MINUS:
+		z.Sub(l, r)
+	case lexer.TOKEN_ASTERISK:
+		z.Mul(l, r)
+	case lexer.TOKEN_SLASH:
+		if r.Sign() == 0 {
+			return nil, errors.New("division by zero")
+		}
+		z.Quo(l, r)
+	case lexer.TOKEN_PERCENT:
+		if r.Sign() == 0 {
+			return nil, errors.New("modulo by zero")
+		}
+		ratMod(z, l, r)
+	case lexer.TOKEN_CARET:
+		if err := ratPow(z, l, r); err != nil {
+			return nil, err
+		}
+	case lexer.TOKEN_EQUAL:
+		return Bool(l.Cmp(r) == 0), nil
+	case lexer.TOKEN_NOT_EQUAL:
+		return Bool(l.Cmp(r) != 0), nil
+	case lexer.TOKEN_LESS:
+		return Bool(l.Cmp(r) < 0), nil
+	case lexer.TOKEN_LESS_EQUAL:
+		return Bool(l.Cmp(r) <= 0), nil
+	case lexer.TOKEN_GREATER:
+		return Bool(l.Cmp(r) > 0), nil
+	case lexer.TOKEN_GREATER_EQUAL:
+		return Bool(l.Cmp(r) >= 0), nil
+	default:
+		return nil, fmt.Errorf("undefined operator '%s'", op.Raw)
+	}
+	return Rational{z}, nil
+}
+
+func (a RationalArithmetic) Negate(v Value) (Value, error) {
+	r, err := a.operand(v)
+	if err != nil {
+		return nil, err
+	}
+	return Rational{new(big.Rat).Neg(r)}, nil
+}
+
+func (a RationalArithmetic) native(name string) (func(args []Value) (Value, error), bool) {
+	fn, ok := rationalBuiltins[name]
+	if !ok {
+		if _, ok := builtins[name]; ok {
+			return func(args []Value) (Value, error) {
+				return nil, fmt.Errorf("%s has no exact result in rational mode", name)
+			}, true
+		}
+		return nil, false
+	}
+
+	return func(args []Value) (Value, error) {
+		operands := make([]*big.Rat, 0, len(args))
+		for _, arg := range args {
+			r, err := a.operand(arg)
+			if err != nil {
+				return nil, err
+			}
+			operands = append(operands, r)
+		}
+
+		z := new(big.Rat)
+		if err := fn(z, operands...); err != nil {
+			return nil, err
+		}
+		return Rational{z}, nil
+	}, true
+}
+
+func (a RationalArithmetic) integer(v Value) (*big.Int, error) {
+	r, err := a.operand(v)
+	if err != nil {
+		return nil, err
+	}
+	if !r.IsInt() {
+		return nil, fmt.Errorf("%s isn't an integer", r.RatString())
+	}
+	return new(big.Int).Set(r.Num()), nil
+}
+
+func (RationalArithmetic) fromInteger(n *big.Int) (Value, error) {
+	return Rational{new(big.Rat).SetInt(n)}, nil
+}
+
+func (a RationalArithmetic) operand(v Value) (*big.Rat, error) {
+	switch v := v.(type) {
+	case Rational:
+		return v.r, nil
+	case Number:
+		r, err := a.FromFloat(float64(v))
+		if err != nil {
+			return nil, err
+		}
+		return r.(Rational).r, nil
+	}
+	return nil, fmt.Errorf("can't use %T in rational arithmetic", v)
+}
+
+// ratLiteral reads a number literal exactly.
+func ratLiteral(raw string) (*big.Rat, error) {
+	if isBaseLiteral(raw) {
+		n, err := baseLiteral(raw)
+		if err != nil {
+			return nil, err
+		}
+		return new(big.Rat).SetInt(n), nil
+	}
+
+	raw = strings.ReplaceAll(raw, "_", "")
+	if i := strings.IndexAny(raw, "eE"); i >= 0 {
+		exp, err := strconv.Atoi(raw[i+1:])
+		if err != nil || exp > maxRationalBits/4 || exp < -maxRationalBits/4 {
+			return nil, errors.New("exponent is too large for a rational number")
+		}
+	}
+
+	r, ok := new(big.Rat).SetString(raw)
+	if !ok {
+		return nil, fmt.Errorf("invalid rational number '%s'", raw)
+	}
+	return r, nil
+}
+
+// ratFromFloat converts f by its shortest decimal form rather than by its
+// exact binary value.
+func ratFromFloat(f float64) (*big.Rat, error) {
+	if math.IsInf(f, 0) || math.IsNaN(f) {
+		return nil, fmt.Errorf("%s isn't a rational number", Number(f))
+	}
+	r, _ := new(big.Rat).SetString(strconv.FormatFloat(f, 'g', -1, 64))
+	return r, nil
+}
+
+var rationalBuiltins = map[string]func(z *big.Rat, args ...*big.Rat) error{
+	"abs": func(z *big.Rat, args ...*big.Rat) error {
+		z.Abs(args[0])
+		return nil
+	},
+	"floor": func(z *big.Rat, args ...*big.Rat) error {
+		//denominators are positive, Div rounds towards negative infinity for them
+		z.SetInt(new(big.Int).Div(args[0].Num(), args[0].Denom()))
+		return nil
+	},
+	"ceil": func(z *big.Rat, args ...*big.Rat) error {
+		z.SetInt(new(big.Int).Div(new(big.Int).Neg(args[0].Num()), args[0].Denom()))
+		z.Neg(z)
+		return nil
+	},
+	"trunc": func(z *big.Rat, args ...*big.Rat) error {
+		z.SetInt(new(big.Int).Quo(args[0].Num(), args[0].Denom()))
+		return nil
+	},
+	"round": func(z *big.Rat, args ...*big.Rat) error {
+		//half away from zero, like math.Round
+		half := new(big.Rat).Abs(args[0])
+		half.Add(half, big.NewRat(1, 2))
+		z.SetInt(new(big.Int).Quo(half.Num(), half.Denom()))
+		if args[0].Sign() < 0 {
+			z.Neg(z)
+		}
+		return nil
+	},
+	"sqrt": func(z *big.Rat, args ...*big.Rat) error {
+		if err := ratPow(z, args[0], big.NewRat(1, 2)); err != nil {
+			return fmt.Errorf("sqrt(%s) isn't a rational number", args[0].RatString())
+		}
+		return nil
+	},
+	"re": func(z *big.Rat, args ...*big.Rat) error {
+		z.Set(args[0])
+		return nil
+	},
+	"im": func(z *big.Rat, args ...*big.Rat) error {
+		z.SetInt64(0)
+		return nil
+	},
+	"conj": func(z *big.Rat, args ...*big.Rat) error {
+		z.Set(args[0])
+		return nil
+	},
+	"min": func(z *big.Rat, args ...*big.Rat) error {
+		z.Set(args[0])
+		for _, arg := range args[1:] {
+			if arg.Cmp(z) < 0 {
+				z.Set(arg)
+			}
+		}
+		return nil
+	},
+	"max": func(z *big.Rat, args ...*big.Rat) error {
+		z.Set(args[0])
+		for _, arg := range args[1:] {
+			if arg.Cmp(z) > 0 {
+				z.Set(arg)
+			}
+		}
+		return nil
+	},
+}
+
+// ratMod is the remainder of truncated division, its sign is the sign of x.
+func ratMod(z *big.Rat, x *big.Rat, y *big.Rat) {
+	q := new(big.Rat).Quo(x, y)
+	trunc := new(big.Rat).SetInt(new(big.Int).Quo(q.Num(), q.Denom()))
+	z.Sub(x, trunc.Mul(trunc, y))
+}
+
+// ratPow computes x^(p/q) exactly. It's an error when the q-th root of x
+// isn't rational.
+func ratPow(z *big.Rat, x *big.Rat, y *big.Rat) error {
+	if !y.Num().IsInt64() || !y.Denom().IsInt64() {
+		return errors.New("exponent is too large for a rational number")
+	}
+	p, q := y.Num().Int64(), y.Denom().Int64()
+
+	base := new(big.Rat).Set(x)
+	if q > 1 {
+		if x.Sign() < 0 && q%2 == 0 {
+			return fmt.Errorf("%s^%s isn't a rational number", ratOperand(x), ratOperand(y))
+		}
+		num, numExact := intRoot(new(big.Int).Abs(x.Num()), q)
+		denom, denomExact := intRoot(x.Denom(), q)
+		if !numExact || !denomExact {
+			return fmt.Errorf("%s^%s isn't a rational number", ratOperand(x), ratOperand(y))
+		}
+		if x.Sign() < 0 {
+			num.Neg(num)
+		}
+		base.SetFrac(num, denom)
+	}
+
+	if p == 0 {
+		z.SetInt64(1)
+		return nil
+	}
+	if p < 0 {
+		if base.Sign() == 0 {
+			return errors.New("division by zero")
+		}
+		base.Inv(base)
+		p = -p
+	}
+	if bits := max(base.Num().BitLen(), base.Denom().BitLen()); bits > 1 && int64(bits) > maxRationalBits/p {
+		return errors.New("result of '^' is too large for a rational number")
+	}
+
+	exp := big.NewInt(p)
+	z.SetFrac(new(big.Int).Exp(base.Num(), exp, nil), new(big.Int).Exp(base.Denom(), exp, nil))
+	return nil
+}
+
+// ratOperand prints r for an error message, in brackets unless it's a
+// non-negative integer.
+func ratOperand(r *big.Rat) string {
+	if r.IsInt() && r.Sign() >= 0 {
+		return r.RatString()
+	}
+	return "(" + r.RatString() + ")"
+}
+
+// intRoot returns the integer k-th root of n >= 0, rounded down, and whether
+// it's exact.
+func intRoot(n *big.Int, k int64) (*big.Int, bool) {
+	one := big.NewInt(1)
+	if n.Cmp(one) <= 0 {
+		return new(big.Int).Set(n), true
+	}
+	if k > int64(n.BitLen()) {
+		return one, false
+	}
+
+	//Newton's method, it goes down to the root from any start above it
+	kInt, kMinusOne := big.NewInt(k), big.NewInt(k-1)
+	x := new(big.Int).Lsh(one, uint((int64(n.BitLen())+k-1)/k))
+	for {
+		y := new(big.Int).Exp(x, kMinusOne, nil)
+		y.Quo(n, y)
+		y.Add(y, new(big.Int).Mul(x, kMinusOne))
+		y.Quo(y, kInt)
+		if y.Cmp(x) >= 0 {
+			break
+		}
+		x = y
+	}
+
+	return x, new(big.Int).Exp(x, kInt, nil).Cmp(n) == 0
+}
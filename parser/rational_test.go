@@ -0,0 +1,97 @@
+package parser
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRationalArithmetic(t *testing.T) {
+	nonErrorTests := []struct {
+		In  string
+		Out string
+	}{
+		{In: "1/3 + 1/6", Out: "1/2"},
+		{In: "0.1 + 0.2 == 0.3", Out: "true"},
+		{In: "1.25e-1 * 8", Out: "1"},
+		{In: "1_000e1_0 / 3", Out: "10000000000000/3"},
+		{In: ".5 + 3.", Out: "7/2"},
+		{In: "2^-2", Out: "1/4"},
+		{In: "8^(1/3)", Out: "2"},
+		{In: "(8/27)^(-2/3)", Out: "9/4"},
+		{In: "(-8)^(1/3)", Out: "-2"},
+		{In: "sqrt(16/9)", Out: "4/3"},
+		{In: "7.5 % 2", Out: "3/2"},
+		{In: "-7 % 3", Out: "-1"},
+		{In: "floor(-5/2) + ceil(-5/2) + trunc(-5/2)", Out: "-7"},
+		{In: "round(-5/2) + round(5/2)", Out: "0"},
+		{In: "max(1/3, 0.3) - min(1/3, 0.3)", Out: "1/30"},
+		{In: "abs(-2/3)", Out: "2/3"},
+		{In: "0x1_0000_0000_0000_0001 / 0b10", Out: "18446744073709551617/2"},
+		{In: "(1 << 100 | 1) - 2^100", Out: "1"},
+	}
+
+	errorTests := []struct {
+		In      string
+		Message string
+	}{
+		{In: "1/0", Message: "division by zero"},
+		{In: "1 % 0", Message: "modulo by zero"},
+		{In: "0^-1", Message: "division by zero"},
+		{In: "2^(1/2)", Message: "2^(1/2) isn't a rational number"},
+		{In: "(-4)^(1/2)", Message: "(-4)^(1/2) isn't a rational number"},
+		{In: "sqrt(2)", Message: "sqrt(2) isn't a rational number"},
+		{In: "1/2 << 1", Message: "'<<' needs integers, 1/2 isn't an integer"},
+		{In: "sin(1)", Message: "sin has no exact result in rational mode"},
+		{In: "2 * pi", Message: "pi is irrational, it has no exact value in rational mode"},
+		{In: "e ^ 2", Message: "e is irrational, it has no exact value in rational mode"},
+		{In: "10^1e9", Message: "result of '^' is too large for a rational number"},
+		{In: "1e999999999", Message: "number node error: exponent is too large for a rational number"},
+		{In: "inf", Message: "'inf': +Inf isn't a rational number"},
+	}
+
+	newEnv := func() *Env {
+		env := NewEnv()
+		env.SetArithmetic(RationalArithmetic{})
+		return env
+	}
+
+	for _, test := range nonErrorTests {
+		t.Run(test.In, func(t *testing.T) {
+			out, err := Eval(parse(t, test.In), newEnv())
+			assert.NoError(t, err)
+			assert.Equal(t, test.Out, out.String())
+		})
+	}
+
+	for _, test := range errorTests {
+		t.Run(test.In, func(t *testing.T) {
+			_, err := Eval(parse(t, test.In), newEnv())
+			var evalErr *EvalError
+			if assert.ErrorAs(t, err, &evalErr) {
+				assert.Equal(t, test.Message, evalErr.Message)
+			}
+		})
+	}
+}
+
+func TestRationalHostValues(t *testing.T) {
+	env := NewEnv()
+	env.SetArithmetic(RationalArithmetic{})
+	env.Set("rate", Number(0.1))
+	env.RegisterFunc("half", func(args ...float64) (float64, error) {
+		return args[0] / 2, nil
+	}, 1)
+
+	out, err := Eval(parse(t, "rate * 3 + half(1/2)"), env)
+	assert.NoError(t, err)
+	assert.Equal(t, "11/20", out.String())
+	assert.Equal(t, "0.55", out.(Rational).FloatString(2))
+	assert.Equal(t, "0.6", out.(Rational).FloatString(1))
+
+	//a registered constant is a host value, it's taken by its decimal form like rate
+	env.RegisterConst("pi", 3.14)
+	out, err = Eval(parse(t, "pi * 2"), env)
+	assert.NoError(t, err)
+	assert.Equal(t, "157/25", out.String())
+}
@@ -0,0 +1,135 @@
+package parser
+
+import (
+	"fmt"
+	"math/big"
+	"slices"
+	"strings"
+
+	"github.com/Yarik7610/expressive/lexer"
+)
+
+// Units files hold one definition per statement:
+// <unit statement> ::= IDENT ("=" (NUMBER | NUMBER? <unit>))?
+//
+// A name alone is a new base unit, like a currency or story points, the other
+// units are a multiple of units defined before, built-in ones, or ones defined
+// anywhere in the same file:
+//
+//	point
+//	sprint = 2 week
+//	velocity = 1 point/sprint
+type unitStatement struct {
+	name   lexer.Token
+	factor *big.Rat
+	unit   UnitExpr
+	base   bool
+}
+
+func parseUnitFile(tokens []lexer.Token) ([]*unitStatement, error) {
+	p := NewParser(tokens)
+	statements := make([]*unitStatement, 0)
+
+	for !p.isEnd() {
+		if p.match(lexer.TOKEN_SEMICOLON) {
+			continue
+		}
+
+		name := p.peek()
+		if name.Type != lexer.TOKEN_IDENT {
+			return nil, &SyntaxError{fmt.Sprintf("expected unit name, found %s", describe(name)), name.Span}
+		}
+		p.advance()
+
+		statement := &unitStatement{name: name, base: true}
+		if !p.atLineBreak() && p.match(lexer.TOKEN_ASSIGN) {
+			factor, unit, err := p.parseUnitValue()
+			if err != nil {
+				return nil, err
+			}
+			statement = &unitStatement{name: name, factor: factor, unit: unit}
+		}
+		if err := p.requireEnd(); err != nil {
+			return nil, err
+		}
+		statements = append(statements, statement)
+	}
+
+	return statements, nil
+}
+
+// unitLoader resolves the statements of a units file in the order their
+// definitions need, not the order they are written in.
+type unitLoader struct {
+	env        *Env
+	statements map[string]*unitStatement
+	defs       map[string]unitDef
+	//stack holds the units being resolved, finding one of them again is a cycle
+	stack []string
+}
+
+func (l *unitLoader) resolve(statement *unitStatement) (unitDef, error) {
+	name := statement.name.Raw
+	if def, ok := l.defs[name]; ok {
+		return def, nil
+	}
+	if i := slices.Index(l.stack, name); i >= 0 {
+		cycle := strings.Join(append(slices.Clone(l.stack[i:]), name), " -> ")
+		return unitDef{}, &EvalError{fmt.Sprintf("unit '%s' is defined in terms of itself: %s", name, cycle), statement.name.Span}
+	}
+
+	def := unitDef{big.NewRat(1, 1), dimension{name: 1}}
+	if !statement.base {
+		l.stack = append(l.stack, name)
+		defer func() { l.stack = l.stack[:len(l.stack)-1] }()
+
+		terms := make([]unitPower, 0, len(statement.unit.Terms))
+		for _, t := range statement.unit.Terms {
+			termDef, err := l.lookup(t.Name)
+			if err != nil {
+				return unitDef{}, err
+			}
+			terms = append(terms, unitPower{t.Name.Raw, t.Power, termDef})
+		}
+
+		unit := Unit{terms}
+		def = unitDef{new(big.Rat).Mul(statement.factor, unit.factor()), unit.dim()}
+	}
+
+	if err := l.env.checkRedefinition(name, def); err != nil {
+		return unitDef{}, &EvalError{err.Error(), statement.name.Span}
+	}
+	l.defs[name] = def
+	return def, nil
+}
+
+// lookup prefers units of the file to the ones already known.
+func (l *unitLoader) lookup(name lexer.Token) (unitDef, error) {
+	if statement, ok := l.statements[name.Raw]; ok {
+		return l.resolve(statement)
+	}
+	if def, ok := l.env.unit(name.Raw); ok {
+		return def, nil
+	}
+	return unitDef{}, &EvalError{fmt.Sprintf("unknown unit '%s'", name.Raw), name.Span}
+}
+
+// describeDimension prints d like a unit of base units, 1 for plain numbers.
+func describeDimension(d dimension) string {
+	if len(d) == 0 {
+		return "1"
+	}
+
+	terms := make([]unitPower, 0, len(d))
+	for base, power := range d {
+		terms = append(terms, unitPower{name: base, power: power})
+	}
+	slices.SortFunc(terms, func(a, b unitPower) int { return strings.Compare(a.name, b.name) })
+	return Unit{terms}.String()
+}
+
+// isUnitName reports whether name reads as a single identifier.
+func isUnitName(name string) bool {
+	tokens, err := lexer.NewLexer(strings.NewReader(name)).Lex()
+	return err == nil && len(tokens) == 2 && tokens[0].Type == lexer.TOKEN_IDENT && tokens[0].Raw == name
+}
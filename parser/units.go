@@ -0,0 +1,624 @@
+package parser
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"slices"
+	"strings"
+
+	"github.com/Yarik7610/expressive/lexer"
+)
+
+// maxUnitPower bounds powers of units, conversion factors are raised to them
+// and m^1000000000 would take all the memory otherwise.
+const maxUnitPower = 100
+
+// dimension maps base units to their powers, the dimension of km/h is
+// {"m": 1, "s": -1}.
+type dimension map[string]int
+
+func (d dimension) equal(other dimension) bool {
+	if len(d) != len(other) {
+		return false
+	}
+	for base, power := range d {
+		if other[base] != power {
+			return false
+		}
+	}
+	return true
+}
+
+// times returns d * other^power.
+func (d dimension) times(other dimension, power int) dimension {
+	result := make(dimension, len(d)+len(other))
+	for base, p := range d {
+		result[base] = p
+	}
+	for base, p := range other {
+		result[base] += p * power
+		if result[base] == 0 {
+			delete(result, base)
+		}
+	}
+	return result
+}
+
+// unitDef is a named unit, one of it is factor times the base units of dim.
+type unitDef struct {
+	factor *big.Rat
+	dim    dimension
+}
+
+type unitPower struct {
+	name  string
+	power int
+	def   unitDef
+}
+
+// Unit is a product of named units with integer powers, like km/h or
+// kg*m/s^2.
+type Unit struct {
+	terms []unitPower
+}
+
+// String prints u like kg*m/s^2, or like s^-1 when all powers are negative.
+func (u Unit) String() string {
+	var b strings.Builder
+	positive := slices.ContainsFunc(u.terms, func(t unitPower) bool { return t.power > 0 })
+
+	for _, t := range u.terms {
+		power := t.power
+		switch {
+		case positive && power < 0:
+			b.WriteString("/")
+			power = -power
+		case b.Len() > 0:
+			b.WriteString("*")
+		}
+		b.WriteString(t.name)
+		if power != 1 {
+			fmt.Fprintf(&b, "^%d", power)
+		}
+	}
+	return b.String()
+}
+
+func (u Unit) factor() *big.Rat {
+	result := big.NewRat(1, 1)
+	for _, t := range u.terms {
+		result.Mul(result, ratPowInt(t.def.factor, t.power))
+	}
+	return result
+}
+
+func (u Unit) dim() dimension {
+	result := dimension{}
+	for _, t := range u.terms {
+		result = result.times(t.def.dim, t.power)
+	}
+	return result
+}
+
+// times returns u * other^sign, where sign is 1 or -1, and the ratio the
+// value of the product must be multiplied by. Units of other that measure the
+// same as a unit of u are converted to it, so km/m cancels out to 1000.
+func (u Unit) times(other Unit, sign int) (Unit, *big.Rat) {
+	terms := slices.Clone(u.terms)
+	ratio := big.NewRat(1, 1)
+
+	for _, t := range other.terms {
+		power := t.power * sign
+		i := slices.IndexFunc(terms, func(own unitPower) bool {
+			return own.name == t.name || (len(own.def.dim) > 0 && own.def.dim.equal(t.def.dim))
+		})
+		if i < 0 {
+			terms = append(terms, unitPower{t.name, power, t.def})
+			continue
+		}
+
+		if terms[i].name != t.name {
+			ratio.Mul(ratio, ratPowInt(new(big.Rat).Quo(t.def.factor, terms[i].def.factor), power))
+		}
+		terms[i].power += power
+	}
+
+	terms = slices.DeleteFunc(terms, func(t unitPower) bool { return t.power == 0 })
+
+	//units that cancel out without sharing a name, like Hz*s, leave a plain number
+	result := Unit{terms}
+	if len(result.dim()) == 0 && slices.ContainsFunc(terms, func(t unitPower) bool { return len(t.def.dim) > 0 }) {
+		return Unit{}, ratio.Mul(ratio, result.factor())
+	}
+	return result, ratio
+}
+
+// pow returns u^(n/d), every power of u times n must divide by d.
+func (u Unit) pow(n int, d int) (Unit, bool) {
+	terms := make([]unitPower, 0, len(u.terms))
+	for _, t := range u.terms {
+		if t.power*n%d != 0 {
+			return Unit{}, false
+		}
+		if power := t.power * n / d; power != 0 {
+			terms = append(terms, unitPower{t.name, power, t.def})
+		}
+	}
+	return Unit{terms}, true
+}
+
+func (u Unit) tooLarge() bool {
+	return slices.ContainsFunc(u.terms, func(t unitPower) bool { return t.power > maxUnitPower || t.power < -maxUnitPower })
+}
+
+// Quantity is a number with a unit, like 5 km. The number belongs to the
+// arithmetic in use.
+type Quantity struct {
+	value Value
+	unit  Unit
+}
+
+func (q Quantity) String() string {
+	return q.value.String() + " " + q.unit.String()
+}
+
+func (q Quantity) kind() string {
+	return "quantity"
+}
+
+// Magnitude returns the number of q without its unit.
+func (q Quantity) Magnitude() Value {
+	return q.value
+}
+
+// Unit returns the unit of q.
+func (q Quantity) Unit() Unit {
+	return q.unit
+}
+
+// newQuantity drops units that cancelled out, leaving just the number.
+func newQuantity(value Value, unit Unit) Value {
+	if len(unit.terms) == 0 {
+		return value
+	}
+	return Quantity{value, unit}
+}
+
+func splitQuantity(v Value) (Value, Unit) {
+	if q, ok := v.(Quantity); ok {
+		return q.value, q.unit
+	}
+	return v, Unit{}
+}
+
+func describeUnit(u Unit) string {
+	if len(u.terms) == 0 {
+		return "number"
+	}
+	return u.String()
+}
+
+// quantityOp applies op to numbers of which at least one has a unit.
+// Additions, remainders and comparisons need operands of the same dimension,
+// the right one is converted to the unit of the left one.
+func quantityOp(arith Arithmetic, op lexer.Token, left Value, right Value) (Value, error) {
+	lv, lu := splitQuantity(left)
+	rv, ru := splitQuantity(right)
+
+	switch op.Type {
+	case lexer.TOKEN_PLUS, lexer.TOKEN_MINUS, lexer.TOKEN_PERCENT,
+		lexer.TOKEN_EQUAL, lexer.TOKEN_NOT_EQUAL, lexer.TOKEN_LESS, lexer.TOKEN_LESS_EQUAL, lexer.TOKEN_GREATER, lexer.TOKEN_GREATER_EQUAL:
+		if !lu.dim().equal(ru.dim()) {
+			return nil, fmt.Errorf("can't apply '%s' to %s and %s, their dimensions differ", op.Raw, describeUnit(lu), describeUnit(ru))
+		}
+		rv, err := scale(arith, rv, new(big.Rat).Quo(ru.factor(), lu.factor()), lu)
+		if err != nil {
+			return nil, err
+		}
+		val, err := arith.Binary(op, lv, rv)
+		if err != nil {
+			return nil, err
+		}
+		if b, ok := val.(Bool); ok {
+			return b, nil
+		}
+		return newQuantity(val, lu), nil
+	case lexer.TOKEN_ASTERISK, lexer.TOKEN_SLASH:
+		sign := 1
+		if op.Type == lexer.TOKEN_SLASH {
+			sign = -1
+		}
+		unit, ratio := lu.times(ru, sign)
+		if unit.tooLarge() {
+			return nil, fmt.Errorf("%s %s %s is too large, unit powers go up to %d", lu, op.Raw, ru, maxUnitPower)
+		}
+		val, err := arith.Binary(op, lv, rv)
+		if err != nil {
+			return nil, err
+		}
+		val, err = scale(arith, val, ratio, unit)
+		if err != nil {
+			return nil, err
+		}
+		return newQuantity(val, unit), nil
+	case lexer.TOKEN_CARET:
+		if len(ru.terms) > 0 {
+			return nil, fmt.Errorf("exponent can't have a unit, got %s", right)
+		}
+		exponent, err := arith.Float(rv)
+		if err != nil {
+			return nil, err
+		}
+		unit, ok := unitPow(lu, exponent)
+		if !ok {
+			return nil, fmt.Errorf("%s ^ %s has no unit with integer powers", lu, rv)
+		}
+		if unit.tooLarge() {
+			return nil, fmt.Errorf("%s ^ %s is too large, unit powers go up to %d", lu, rv, maxUnitPower)
+		}
+		val, err := arith.Binary(op, lv, rv)
+		if err != nil {
+			return nil, err
+		}
+		return newQuantity(val, unit), nil
+	}
+	return nil, fmt.Errorf("can't apply '%s' to %s and %s", op.Raw, left.kind(), right.kind())
+}
+
+// unitPow raises u to a float exponent that is a fraction with a small
+// denominator, like 2 or 0.5.
+func unitPow(u Unit, exponent float64) (Unit, bool) {
+	for d := 1; d <= 12; d++ {
+		if n := exponent * float64(d); n == math.Trunc(n) && math.Abs(n) <= math.MaxInt32 {
+			return u.pow(int(n), d)
+		}
+	}
+	return Unit{}, false
+}
+
+// convert returns v in unit, which must measure the same.
+func convert(arith Arithmetic, v Value, unit Unit) (Value, error) {
+	value, from := splitQuantity(v)
+	if _, ok := value.(Bool); ok {
+		return nil, fmt.Errorf("can't convert bool to %s", unit)
+	}
+	if !from.dim().equal(unit.dim()) {
+		return nil, fmt.Errorf("can't convert %s to %s, their dimensions differ", describeUnit(from), unit)
+	}
+
+	value, err := scale(arith, value, new(big.Rat).Quo(from.factor(), unit.factor()), unit)
+	if err != nil {
+		return nil, err
+	}
+	return newQuantity(value, unit), nil
+}
+
+// scale multiplies v by r, the result is in unit. Integer mode can't hold a
+// fraction, rather than truncate it like "/" does the conversion fails.
+func scale(arith Arithmetic, v Value, r *big.Rat, unit Unit) (Value, error) {
+	if r.Cmp(big.NewRat(1, 1)) == 0 {
+		return v, nil
+	}
+
+	if ints, ok := arith.(IntegerArithmetic); ok {
+		n, err := ints.integer(v)
+		if err != nil {
+			return nil, err
+		}
+		product := new(big.Rat).Mul(new(big.Rat).SetInt(n), r)
+		if !product.IsInt() {
+			return nil, fmt.Errorf("%s isn't an integer", newQuantity(Rational{product}, unit))
+		}
+		return ints.fromInteger(product.Num())
+	}
+
+	num, err := arith.Literal(r.Num().String())
+	if err != nil {
+		return nil, err
+	}
+	v, err = arith.Binary(lexer.Token{Type: lexer.TOKEN_ASTERISK, Raw: "*"}, v, num)
+	if err != nil || r.IsInt() {
+		return v, err
+	}
+
+	den, err := arith.Literal(r.Denom().String())
+	if err != nil {
+		return nil, err
+	}
+	return arith.Binary(lexer.Token{Type: lexer.TOKEN_SLASH, Raw: "/"}, v, den)
+}
+
+// quantityArgs takes the units off arguments of function name. Functions
+// like abs or max give a result in the unit of their first argument, the
+// other arguments are converted to it. sqrt and cbrt take roots of the unit.
+func quantityArgs(name string, args []Value, arith Arithmetic) ([]Value, Unit, error) {
+	values := make([]Value, len(args))
+	_, unit := splitQuantity(args[0])
+
+	switch name {
+	case "abs", "floor", "ceil", "round", "trunc", "min", "max", "hypot", "re", "conj":
+		for i, arg := range args {
+			value, err := convert(arith, arg, unit)
+			if err != nil && len(unit.terms) == 0 {
+				err = fmt.Errorf("can't use %s in %s with numbers without units", describeUnit(arg.(Quantity).unit), name)
+			}
+			if err != nil {
+				return nil, Unit{}, err
+			}
+			values[i], _ = splitQuantity(value)
+		}
+		return values, unit, nil
+	case "sqrt", "cbrt":
+		root := 2
+		if name == "cbrt" {
+			root = 3
+		}
+		result, ok := unit.pow(1, root)
+		if !ok {
+			return nil, Unit{}, fmt.Errorf("%s of %s has no unit with integer powers", name, unit)
+		}
+		values[0], _ = splitQuantity(args[0])
+		return values, result, nil
+	}
+
+	for i, arg := range args {
+		if q, ok := arg.(Quantity); ok {
+			return nil, Unit{}, fmt.Errorf("%s expects numbers without units, argument %d is in %s", name, i+1, q.unit)
+		}
+	}
+	return args, Unit{}, nil
+}
+
+type unitPrefix struct {
+	name   string
+	factor *big.Rat
+}
+
+// unitPrefixes are the SI prefixes, units marked prefixable accept them. They
+// are tried in order, so da comes before d.
+var unitPrefixes = []unitPrefix{
+	{"da", ratPow10(1)},
+	{"Q", ratPow10(30)}, {"R", ratPow10(27)}, {"Y", ratPow10(24)}, {"Z", ratPow10(21)},
+	{"E", ratPow10(18)}, {"P", ratPow10(15)}, {"T", ratPow10(12)}, {"G", ratPow10(9)},
+	{"M", ratPow10(6)}, {"k", ratPow10(3)}, {"h", ratPow10(2)},
+	{"d", ratPow10(-1)}, {"c", ratPow10(-2)}, {"m", ratPow10(-3)}, {"u", ratPow10(-6)},
+	{"µ", ratPow10(-6)}, {"n", ratPow10(-9)}, {"p", ratPow10(-12)}, {"f", ratPow10(-15)},
+	{"a", ratPow10(-18)}, {"z", ratPow10(-21)}, {"y", ratPow10(-24)}, {"r", ratPow10(-27)},
+	{"q", ratPow10(-30)},
+}
+
+type unitDefinition struct {
+	name string
+	// definition is a number, a unit or both, like "1609.344 m". An empty
+	// definition makes a base unit.
+	definition string
+	prefixable bool
+}
+
+// builtinUnitDefinitions are defined in order, each one only uses units
+// before it.
+var builtinUnitDefinitions = []unitDefinition{
+	{"m", "", true},
+	{"g", "", true},
+	{"s", "", true},
+	{"A", "", true},
+	{"K", "", true},
+	{"mol", "", true},
+	{"cd", "", true},
+
+	{"meter", "m", false},
+	{"metre", "m", false},
+	{"ft", "0.3048 m", false},
+	{"foot", "ft", false},
+	{"feet", "ft", false},
+	{"inch", "0.0254 m", false},
+	{"yd", "0.9144 m", false},
+	{"mi", "1609.344 m", false},
+	{"mile", "mi", false},
+	{"nmi", "1852 m", false},
+
+	{"ha", "10000 m^2", false},
+	{"acre", "4046.8564224 m^2", false},
+	{"L", "0.001 m^3", true},
+	{"l", "L", true},
+	{"liter", "L", false},
+	{"litre", "L", false},
+	{"gal", "3.785411784 L", false},
+
+	{"gram", "g", false},
+	{"kilogram", "kg", false},
+	{"t", "1000 kg", false},
+	{"lb", "0.45359237 kg", false},
+	{"pound", "lb", false},
+	{"oz", "0.028349523125 kg", false},
+
+	{"second", "s", false},
+	{"min", "60 s", false},
+	{"minute", "min", false},
+	{"h", "3600 s", false},
+	{"hour", "h", false},
+	{"day", "86400 s", false},
+	{"week", "7 day", false},
+	{"yr", "365.25 day", false},
+	{"year", "yr", false},
+
+	{"mph", "mi/h", false},
+	{"kph", "km/h", false},
+	{"kn", "nmi/h", false},
+
+	{"Hz", "s^-1", true},
+	{"N", "kg*m/s^2", true},
+	{"lbf", "4.4482216152605 N", false},
+	{"Pa", "N/m^2", true},
+	{"bar", "100000 Pa", true},
+	{"atm", "101325 Pa", false},
+	{"psi", "lbf/inch^2", false},
+	{"J", "N*m", true},
+	{"Wh", "3600 J", true},
+	{"cal", "4.184 J", true},
+	{"W", "J/s", true},
+	{"hp", "745.69987158227022 W", false},
+	{"C", "A*s", true},
+	{"V", "W/A", true},
+	{"ohm", "V/A", true},
+}
+
+// unitRegistry holds named units and finds prefixed ones, like km.
+type unitRegistry struct {
+	units      map[string]unitDef
+	prefixable map[string]bool
+}
+
+func newUnitRegistry() *unitRegistry {
+	return &unitRegistry{units: make(map[string]unitDef), prefixable: make(map[string]bool)}
+}
+
+var builtinUnits = func() *unitRegistry {
+	r := newUnitRegistry()
+	for _, d := range builtinUnitDefinitions {
+		if err := r.define(d, r.lookup); err != nil {
+			panic(fmt.Sprintf("unit %s: %s", d.name, err))
+		}
+	}
+	return r
+}()
+
+// define adds d, looking up the units of its definition with lookup.
+func (r *unitRegistry) define(d unitDefinition, lookup func(name string) (unitDef, bool)) error {
+	def := unitDef{big.NewRat(1, 1), dimension{d.name: 1}}
+	if d.definition != "" {
+		factor, unit, err := parseUnitDefinition(d.definition)
+		if err != nil {
+			return err
+		}
+		resolved, err := resolveUnit(unit, lookup)
+		if err != nil {
+			return err
+		}
+		def = unitDef{factor.Mul(factor, resolved.factor()), resolved.dim()}
+	}
+
+	r.units[d.name] = def
+	r.prefixable[d.name] = d.prefixable
+	return nil
+}
+
+func (r *unitRegistry) lookup(name string) (unitDef, bool) {
+	if def, ok := r.units[name]; ok {
+		return def, true
+	}
+	for _, prefix := range unitPrefixes {
+		rest, ok := strings.CutPrefix(name, prefix.name)
+		if !ok || !r.prefixable[rest] {
+			continue
+		}
+		def := r.units[rest]
+		return unitDef{new(big.Rat).Mul(prefix.factor, def.factor), def.dim}, true
+	}
+	return unitDef{}, false
+}
+
+// UnitExpr is a unit written in an expression, like m/s^2 in 9.8 m/s^2.
+type UnitExpr struct {
+	Terms []UnitTerm
+	Span  lexer.Span
+}
+
+// UnitTerm is a named unit of UnitExpr, Power is negative for units after "/".
+type UnitTerm struct {
+	Name  lexer.Token
+	Power int
+}
+
+func (ue UnitExpr) String() string {
+	terms := make([]unitPower, 0, len(ue.Terms))
+	for _, t := range ue.Terms {
+		terms = append(terms, unitPower{name: t.Name.Raw, power: t.Power})
+	}
+	return Unit{terms}.String()
+}
+
+// unitError is an unknown unit of an expression, it knows where the unit is.
+type unitError struct {
+	name lexer.Token
+}
+
+func (e *unitError) Error() string {
+	return fmt.Sprintf("unknown unit '%s'", e.name.Raw)
+}
+
+func resolveUnit(expr UnitExpr, lookup func(name string) (unitDef, bool)) (Unit, error) {
+	terms := make([]unitPower, 0, len(expr.Terms))
+	for _, t := range expr.Terms {
+		def, ok := lookup(t.Name.Raw)
+		if !ok {
+			return Unit{}, &unitError{t.Name}
+		}
+		terms = append(terms, unitPower{t.Name.Raw, t.Power, def})
+	}
+	return Unit{terms}, nil
+}
+
+// parseUnitDefinition reads a definition like "1609.344 m", "mi/h" or "12".
+func parseUnitDefinition(definition string) (*big.Rat, UnitExpr, error) {
+	tokens, err := lexer.NewLexer(strings.NewReader(definition)).Lex()
+	if err != nil {
+		return nil, UnitExpr{}, err
+	}
+
+	p := NewParser(tokens)
+	factor, unit, err := p.parseUnitValue()
+	if err != nil {
+		return nil, UnitExpr{}, err
+	}
+	if !p.isEnd() {
+		return nil, UnitExpr{}, &SyntaxError{fmt.Sprintf("expected end of unit definition, found %s", describe(p.peek())), p.peek().Span}
+	}
+	return factor, unit, nil
+}
+
+// parseUnitValue reads a number, a unit or both up to the end of the
+// statement. The number must be positive, there are no units of zero size.
+func (p *Parser) parseUnitValue() (*big.Rat, UnitExpr, error) {
+	factor := big.NewRat(1, 1)
+	hasNumber := p.check(lexer.TOKEN_NUMBER)
+	if hasNumber {
+		number := p.advance()
+		r, err := ratLiteral(number.Raw)
+		if err != nil {
+			return nil, UnitExpr{}, &SyntaxError{err.Error(), number.Span}
+		}
+		if r.Sign() == 0 {
+			return nil, UnitExpr{}, &SyntaxError{"unit can't be 0", number.Span}
+		}
+		factor = r
+	}
+
+	if p.atLineBreak() && !hasNumber {
+		return nil, UnitExpr{}, &SyntaxError{"expected number or unit, found end of line", p.previous().Span}
+	}
+	if p.isEnd() || p.check(lexer.TOKEN_SEMICOLON) || p.atLineBreak() {
+		if !hasNumber {
+			return nil, UnitExpr{}, &SyntaxError{fmt.Sprintf("expected number or unit, found %s", describe(p.peek())), p.peek().Span}
+		}
+		return factor, UnitExpr{}, nil
+	}
+
+	unit, err := p.parseUnit()
+	if err != nil {
+		return nil, UnitExpr{}, err
+	}
+	return factor, unit, nil
+}
+
+// ratPowInt returns x^n, n is a unit power and stays within maxUnitPower.
+func ratPowInt(x *big.Rat, n int) *big.Rat {
+	num, denom := x.Num(), x.Denom()
+	if n < 0 {
+		num, denom = denom, num
+		n = -n
+	}
+	exp := big.NewInt(int64(n))
+	return new(big.Rat).SetFrac(new(big.Int).Exp(num, exp, nil), new(big.Int).Exp(denom, exp, nil))
+}
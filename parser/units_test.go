@@ -0,0 +1,319 @@
+package parser
+
+import (
+	"errors"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/Yarik7610/expressive/lexer"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEvalUnits(t *testing.T) {
+	nonErrorTests := []struct {
+		In  string
+		Out string
+	}{
+		{In: "5 km + 300 m", Out: "5.3 km"},
+		{In: "60 mph in m/s", Out: "26.8224 m/s"},
+		{In: "1 mi to ft", Out: "5280 ft"},
+		{In: "9.8 m/s^2 * 2 s", Out: "19.6 m/s"},
+		{In: "5 m * 2 m", Out: "10 m^2"},
+		{In: "(5 m/s) * (2 h)", Out: "36000 m"},
+		{In: "10 km / 500 m", Out: "20"},
+		{In: "1 Hz * 1 s", Out: "1"},
+		{In: "(2 m) ^ 2", Out: "4 m^2"},
+		{In: "sqrt(9 m^2)", Out: "3 m"},
+		{In: "-5 km to m", Out: "-5000 m"},
+		{In: "1 kWh in J", Out: "3.6e+06 J"},
+		{In: "1 N in kg*m/s^2", Out: "1 kg*m/s^2"},
+		{In: "1 µm in nm", Out: "1000 nm"},
+		{In: "1 dam in m", Out: "10 m"},
+		{In: "2 min + 30 s", Out: "2.5 min"},
+		{In: "5 km > 300 m", Out: "true"},
+		{In: "max(1 km, 300 m)", Out: "1 km"},
+		{In: "abs(-2 s)", Out: "2 s"},
+		{In: "(5 km in m) + 1 m", Out: "5001 m"},
+		{In: "x = 2 h; x / 4", Out: "0.5 h"},
+		{In: "2 s^-1", Out: "2 s^-1"},
+		{In: "n = 4; 10 m / n", Out: "2.5 m"},
+		{In: "k = 2; 3 m * k^2 / s", Out: "12 m/s"},
+		{In: "dt = 2 s; 10 m / dt", Out: "5 m/s"},
+	}
+
+	errorTests := []struct {
+		In      string
+		Message string
+	}{
+		{In: "3 kg + 2 s", Message: "can't apply '+' to kg and s, their dimensions differ"},
+		{In: "1 m + 1", Message: "can't apply '+' to m and number, their dimensions differ"},
+		{In: "5 kg in m", Message: "can't convert kg to m, their dimensions differ"},
+		{In: "2 in m", Message: "can't convert number to m, their dimensions differ"},
+		{In: "3 foo", Message: "unknown unit 'foo'"},
+		{In: "2 ^ (1 m)", Message: "exponent can't have a unit, got 1 m"},
+		{In: "sqrt(2 m)", Message: "sqrt of m has no unit with integer powers"},
+		{In: "sin(1 m)", Message: "sin expects numbers without units, argument 1 is in m"},
+		{In: "min(1, 2 m)", Message: "can't use m in min with numbers without units"},
+		{In: "1 m & 1", Message: "can't apply '&' to quantity and number"},
+		{In: "h = 2; 100 km / h", Message: "'h' is both a variable and a unit"},
+		{In: "x = 2; 3 m / x / y", Message: "unknown unit 'y'"},
+		{In: "(2 m)^100000", Message: "m ^ 100000 is too large, unit powers go up to 100"},
+		{In: "x = 1 m^60; x * x", Message: "m^60 * m^60 is too large, unit powers go up to 100"},
+	}
+
+	for _, test := range nonErrorTests {
+		t.Run(test.In, func(t *testing.T) {
+			out, err := Eval(parse(t, test.In), NewEnv())
+			if assert.NoError(t, err) {
+				assert.Equal(t, test.Out, out.String())
+			}
+		})
+	}
+
+	for _, test := range errorTests {
+		t.Run(test.In, func(t *testing.T) {
+			_, err := Eval(parse(t, test.In), NewEnv())
+			var evalErr *EvalError
+			if assert.ErrorAs(t, err, &evalErr) {
+				assert.Equal(t, test.Message, evalErr.Message)
+			}
+		})
+	}
+}
+
+func TestUnitsExact(t *testing.T) {
+	env := NewEnv()
+	env.SetArithmetic(RationalArithmetic{})
+
+	out, err := Eval(parse(t, "1 ft in inch"), env)
+	if assert.NoError(t, err) {
+		q := out.(Quantity)
+		assert.Equal(t, Rational{big.NewRat(12, 1)}, q.Magnitude())
+		assert.Equal(t, "inch", q.Unit().String())
+	}
+
+	out, err = Eval(parse(t, "1 m in ft"), env)
+	if assert.NoError(t, err) {
+		assert.Equal(t, "1250/381 ft", out.String())
+	}
+
+	out, err = Eval(parse(t, "1 km^100 == 1e300 m^100"), env)
+	if assert.NoError(t, err) {
+		assert.Equal(t, "true", out.String())
+	}
+}
+
+func TestUnitsInteger(t *testing.T) {
+	env := NewEnv()
+	env.SetArithmetic(IntegerArithmetic{})
+
+	nonErrorTests := []struct {
+		In  string
+		Out string
+	}{
+		{In: "1 km in m", Out: "1000 m"},
+		{In: "2 inch + 1 ft", Out: "14 inch"},
+		{In: "(3 m/s) * (2 min)", Out: "360 m"},
+	}
+
+	errorTests := []struct {
+		In      string
+		Message string
+	}{
+		{In: "1 km in mi", Message: "15625/25146 mi isn't an integer"},
+		{In: "1 ft in m", Message: "381/1250 m isn't an integer"},
+		{In: "1 m + 1 ft", Message: "381/1250 m isn't an integer"},
+	}
+
+	for _, test := range nonErrorTests {
+		t.Run(test.In, func(t *testing.T) {
+			out, err := Eval(parse(t, test.In), env)
+			if assert.NoError(t, err) {
+				assert.Equal(t, test.Out, out.String())
+			}
+		})
+	}
+
+	for _, test := range errorTests {
+		t.Run(test.In, func(t *testing.T) {
+			_, err := Eval(parse(t, test.In), env)
+			var evalErr *EvalError
+			if assert.ErrorAs(t, err, &evalErr) {
+				assert.Equal(t, test.Message, evalErr.Message)
+			}
+		})
+	}
+}
+
+func TestUnitPrefixesOrder(t *testing.T) {
+	//a longer prefix must be tried before the prefixes it starts with, so da isn't read as d
+	for i, prefix := range unitPrefixes {
+		for _, later := range unitPrefixes[i+1:] {
+			assert.False(t, strings.HasPrefix(later.name, prefix.name), "%s comes before %s", prefix.name, later.name)
+		}
+	}
+}
+
+func TestParserUnits(t *testing.T) {
+	nonErrorTests := []struct {
+		In   string
+		Tree string
+	}{
+		{In: "9.8 m/s^2", Tree: "9.8 m/s^2"},
+		{In: "5 km + 300 m", Tree: "+\n 5 km\n 300 m"},
+		{In: "5 m * sqrt(2)", Tree: "*\n 5 m\n sqrt()\n   2"},
+		{In: "60 mph in m/s", Tree: "in m/s\n 60 mph"},
+		{In: "1 km to m in decimal", Tree: "in decimal\n to m\n   1 km"},
+		{In: "(1 km in m) * 2", Tree: "*\n in m\n   1 km\n 2"},
+	}
+
+	errorTests := []struct {
+		In      string
+		Message string
+	}{
+		{In: "2 m^x", Message: "expected integer power, found 'x'"},
+		{In: "2 m^1.5", Message: "expected integer power, found '1.5'"},
+		{In: "1 m^999999999", Message: "unit power 999999999 is too large, unit powers go up to 100"},
+		{In: "5 m to", Message: "expected unit after 'to', found end of input"},
+		{In: "2 in hex in m", Message: "the result already has a format, found 'in'"},
+	}
+
+	for _, test := range nonErrorTests {
+		t.Run(test.In, func(t *testing.T) {
+			out := parse(t, test.In)
+			if assert.Len(t, out, 1) {
+				assert.Equal(t, test.Tree, out[0].String(0))
+			}
+		})
+	}
+
+	for _, test := range errorTests {
+		t.Run(test.In, func(t *testing.T) {
+			tokens, err := lexer.NewLexer(strings.NewReader(test.In)).Lex()
+			assert.NoError(t, err)
+
+			_, err = NewParser(tokens).Parse()
+			var errs ErrorList
+			if assert.ErrorAs(t, err, &errs) && assert.Len(t, errs, 1) {
+				assert.Equal(t, test.Message, errs[0].Message)
+			}
+		})
+	}
+}
+
+func TestLoadUnits(t *testing.T) {
+	env := NewEnv()
+	err := env.LoadUnits(strings.NewReader(`# story points
+point
+velocity = 1 point/sprint
+sprint = 2 week
+
+USD
+EUR = 1.08 USD; GBP = 1.27 USD
+t = 907.18474 kg
+`))
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	nonErrorTests := []struct {
+		In  string
+		Out string
+	}{
+		{In: "3 sprint in day", Out: "42 day"},
+		{In: "20 point / 1 sprint in point/week", Out: "10 point/week"},
+		{In: "2 velocity * 1 sprint in point", Out: "2 point"},
+		{In: "127 EUR in GBP", Out: "108 GBP"},
+		{In: "1 t in lb", Out: "2000 lb"},
+	}
+
+	for _, test := range nonErrorTests {
+		t.Run(test.In, func(t *testing.T) {
+			out, err := Eval(parse(t, test.In), env)
+			if assert.NoError(t, err) {
+				assert.Equal(t, test.Out, out.String())
+			}
+		})
+	}
+
+	_, err = Eval(parse(t, "1 EUR + 1 point"), env)
+	assert.EqualError(t, err, "eval: 1:1: can't apply '+' to EUR and point, their dimensions differ")
+}
+
+func TestLoadUnitsErrors(t *testing.T) {
+	errorTests := []struct {
+		Name    string
+		In      string
+		Message string
+		Line    int
+	}{
+		{Name: "cycle", In: "a = 2 b\nb = 3 c\nc = 1 a", Message: "unit 'a' is defined in terms of itself: a -> b -> c -> a", Line: 1},
+		{Name: "itself", In: "a = 2 a", Message: "unit 'a' is defined in terms of itself: a -> a", Line: 1},
+		{Name: "dimension", In: "x = 1 m\nh = 60 kg", Message: "'h' has dimension s, it can't be redefined with dimension g", Line: 2},
+		{Name: "new base", In: "x = 1 m\nN", Message: "'N' has dimension g*m/s^2, it can't be redefined with dimension N", Line: 2},
+		{Name: "unknown unit", In: "x = 2 foo", Message: "unknown unit 'foo'", Line: 1},
+		{Name: "twice", In: "x = 2\nx = 3 m", Message: "unit 'x' is already defined at 1:1", Line: 2},
+		{Name: "zero", In: "x = 0 m", Message: "unit can't be 0", Line: 1},
+		{Name: "no definition", In: "x =\ny = 1 m", Message: "expected number or unit, found end of line", Line: 1},
+		{Name: "no name", In: "2 = m", Message: "expected unit name, found '2'", Line: 1},
+	}
+
+	for _, test := range errorTests {
+		t.Run(test.Name, func(t *testing.T) {
+			env := NewEnv()
+			err := env.LoadUnits(strings.NewReader(test.In))
+
+			var syntaxErr *SyntaxError
+			var evalErr *EvalError
+			switch {
+			case errors.As(err, &syntaxErr):
+				assert.Equal(t, test.Message, syntaxErr.Message)
+				assert.Equal(t, test.Line, syntaxErr.Span.Start.Line)
+			case assert.ErrorAs(t, err, &evalErr):
+				assert.Equal(t, test.Message, evalErr.Message)
+				assert.Equal(t, test.Line, evalErr.Span.Start.Line)
+			}
+			assert.Empty(t, env.units)
+		})
+	}
+}
+
+func TestDefineUnit(t *testing.T) {
+	env := NewEnv()
+	assert.NoError(t, env.DefineUnit("point", ""))
+	assert.NoError(t, env.DefineUnit("sprint", "2 week"))
+	assert.NoError(t, env.DefineUnit("dozen", "12"))
+
+	out, err := Eval(parse(t, "30 point / 3 sprint * 2 dozen"), env)
+	if assert.NoError(t, err) {
+		assert.Equal(t, "20 point/sprint*dozen", out.String())
+	}
+
+	assert.EqualError(t, env.DefineUnit("2x", "1 m"), "'2x' can't be a unit name")
+	assert.EqualError(t, env.DefineUnit("in", "1 m"), "'in' can't be a unit name")
+	assert.EqualError(t, env.DefineUnit("sprint", "3 kg"), "'sprint' has dimension s, it can't be redefined with dimension g")
+	assert.EqualError(t, env.DefineUnit("x", "2 parsec"), "eval: 1:3: unknown unit 'parsec'")
+	assert.EqualError(t, env.DefineUnit("x", "2 m +"), "parser: 1:5: expected end of unit definition, found '+'")
+}
+
+func TestUnitLoaderStack(t *testing.T) {
+	//a failed resolution leaves no unit on the stack, resolving the next one reports no cycle
+	tokens, err := lexer.NewLexer(strings.NewReader("a = 2 foo\nb = 3 a")).Lex()
+	assert.NoError(t, err)
+	statements, err := parseUnitFile(tokens)
+	assert.NoError(t, err)
+
+	l := unitLoader{env: NewEnv(), statements: make(map[string]*unitStatement), defs: make(map[string]unitDef)}
+	for _, statement := range statements {
+		l.statements[statement.name.Raw] = statement
+	}
+
+	_, err = l.resolve(statements[0])
+	assert.EqualError(t, err, "eval: 1:7: unknown unit 'foo'")
+	assert.Empty(t, l.stack)
+
+	_, err = l.resolve(statements[1])
+	assert.EqualError(t, err, "eval: 1:7: unknown unit 'foo'")
+	assert.Empty(t, l.stack)
+}
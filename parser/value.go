@@ -0,0 +1,90 @@
+package parser
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/Yarik7610/expressive/lexer"
+)
+
+// Value is a result of evaluation: a Bool, a number of the Arithmetic in
+// use, like Number or BigFloat, or a Quantity of such a number.
+type Value interface {
+	String() string
+	kind() string
+}
+
+type Number float64
+
+func (n Number) String() string {
+	return strconv.FormatFloat(float64(n), 'g', -1, 64)
+}
+
+func (n Number) kind() string {
+	return "number"
+}
+
+// Bool is a result of comparisons and logical operators. Booleans are never converted to numbers
+// or back, using one where the other is expected is an evaluation error.
+type Bool bool
+
+func (b Bool) String() string {
+	return strconv.FormatBool(bool(b))
+}
+
+func (b Bool) kind() string {
+	return "bool"
+}
+
+// binaryOp leaves numbers to arith, only booleans, bitwise operators and
+// units are handled here.
+func binaryOp(arith Arithmetic, op lexer.Token, left Value, right Value) (Value, error) {
+	lb, lok := left.(Bool)
+	rb, rok := right.(Bool)
+	if !lok && !rok {
+		_, lq := left.(Quantity)
+		_, rq := right.(Quantity)
+		if lq || rq {
+			return quantityOp(arith, op, left, right)
+		}
+
+		switch op.Type {
+		case lexer.TOKEN_AMPERSAND, lexer.TOKEN_PIPE, lexer.TOKEN_XOR, lexer.TOKEN_SHIFT_LEFT, lexer.TOKEN_SHIFT_RIGHT:
+			return bitwiseOp(arith, op, left, right)
+		}
+		return arith.Binary(op, left, right)
+	}
+
+	if lok && rok {
+		switch op.Type {
+		case lexer.TOKEN_EQUAL:
+			return Bool(lb == rb), nil
+		case lexer.TOKEN_NOT_EQUAL:
+			return Bool(lb != rb), nil
+		}
+	}
+
+	return nil, fmt.Errorf("can't apply '%s' to %s and %s", op.Raw, left.kind(), right.kind())
+}
+
+func unaryOp(arith Arithmetic, op lexer.Token, right Value) (Value, error) {
+	if q, ok := right.(Quantity); ok && op.Type == lexer.TOKEN_MINUS {
+		val, err := arith.Negate(q.value)
+		if err != nil {
+			return nil, err
+		}
+		return Quantity{val, q.unit}, nil
+	}
+
+	b, ok := right.(Bool)
+	_, isQuantity := right.(Quantity)
+	switch {
+	case !ok && op.Type == lexer.TOKEN_MINUS:
+		return arith.Negate(right)
+	case !ok && !isQuantity && op.Type == lexer.TOKEN_TILDE:
+		return bitwiseNot(arith, op, right)
+	case ok && op.Type == lexer.TOKEN_NOT:
+		return !b, nil
+	}
+	return nil, fmt.Errorf("can't apply '%s' to %s", op.Raw, right.kind())
+}